@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"time"
+	_ "time/tzdata" // часовые пояса для TODO_TIMEZONE и X-Timezone в образе без tzdata
 
 	"github.com/NarthurN/TODO-API-web/internal/config"
 	"github.com/NarthurN/TODO-API-web/internal/server"
+	"github.com/NarthurN/TODO-API-web/pkg/calendar"
 	"github.com/NarthurN/TODO-API-web/pkg/db"
 	"github.com/NarthurN/TODO-API-web/pkg/loger"
 )
@@ -20,6 +25,17 @@ func main() {
 	}
 	defer db.Close()
 
+	if err := loadCalendar(db); err != nil {
+		loger.L.Error("loadCalendar: loading holidays", "err", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if config.Cfg.TrashRetention > 0 {
+		go purgeTrash(ctx, db, config.Cfg.TrashRetention)
+	}
+
 	server := server.New(db)
 
 	if err := server.Run(); err != nil {
@@ -27,3 +43,50 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// loadCalendar заполняет производственный календарь из файла TODO_HOLIDAYS_FILE
+// и таблицы holidays. Записи из базы имеют приоритет над файлом.
+func loadCalendar(storage *db.TaskStorage) error {
+	if config.Cfg.TODO_HOLIDAYS_FILE != "" {
+		if err := calendar.C.LoadFile(config.Cfg.TODO_HOLIDAYS_FILE); err != nil {
+			return fmt.Errorf("calendar.C.LoadFile: %w", err)
+		}
+	}
+
+	days, err := storage.GetHolidays()
+	if err != nil {
+		return fmt.Errorf("storage.GetHolidays: %w", err)
+	}
+	for _, day := range days {
+		if err := calendar.C.Set(day); err != nil {
+			return fmt.Errorf("calendar.C.Set: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// trashPurgeInterval — как часто из корзины удаляются задачи с истёкшим сроком хранения.
+const trashPurgeInterval = time.Hour
+
+// purgeTrash окончательно удаляет задачи, пролежавшие в корзине дольше retention:
+// сразу при запуске и затем раз в trashPurgeInterval, пока не отменён ctx.
+func purgeTrash(ctx context.Context, storage *db.TaskStorage, retention time.Duration) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := storage.PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			loger.L.Error("storage.PurgeTrash: purging trash", "err", err)
+		} else if purged > 0 {
+			loger.L.Info("trash purged", "tasks", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
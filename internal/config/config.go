@@ -2,8 +2,10 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 
-	"go1f/pkg/loger"
+	"github.com/NarthurN/TODO-API-web/pkg/loger"
 
 	"github.com/joho/godotenv"
 )
@@ -11,10 +13,35 @@ import (
 var Cfg *Config
 
 type Config struct {
-	TODO_PORT   string
-	TODO_DBFILE string
+	TODO_PORT          string
+	TODO_DBFILE        string
+	TODO_HOLIDAYS_FILE string
+	TODO_TIMEZONE      string
+	// Location — разобранный TODO_TIMEZONE
+	Location *time.Location
+	// TODO_TRASH_RETENTION — сколько удалённая задача лежит в корзине, например "720h";
+	// "0" отключает очистку корзины.
+	TODO_TRASH_RETENTION string
+	// TrashRetention — разобранный TODO_TRASH_RETENTION
+	TrashRetention time.Duration
+	// TODO_UNDO_WINDOW — сколько после выполнения задачи его можно отменить, например "15m";
+	// "0" отключает отмену.
+	TODO_UNDO_WINDOW string
+	// UndoWindow — разобранный TODO_UNDO_WINDOW
+	UndoWindow time.Duration
+	// TODO_SUBTASKS_BLOCK_DONE — "true" запрещает выполнять задачу, пока в её
+	// чек-листе есть невыполненные пункты.
+	TODO_SUBTASKS_BLOCK_DONE string
+	// BlockOpenSubtasks — разобранный TODO_SUBTASKS_BLOCK_DONE
+	BlockOpenSubtasks bool
 }
 
+// defaultTrashRetention — срок хранения задач в корзине по умолчанию, 30 дней.
+const defaultTrashRetention = "720h"
+
+// defaultUndoWindow — срок отмены выполнения по умолчанию.
+const defaultUndoWindow = "15m"
+
 func Init() {
 	Cfg = &Config{}
 	if err := godotenv.Load(); err != nil {
@@ -31,4 +58,49 @@ func Init() {
 	if Cfg.TODO_DBFILE == "" {
 		Cfg.TODO_DBFILE = "scheduler.db"
 	}
+
+	Cfg.TODO_HOLIDAYS_FILE = os.Getenv("TODO_HOLIDAYS_FILE")
+
+	Cfg.TODO_TIMEZONE = os.Getenv("TODO_TIMEZONE")
+	if Cfg.TODO_TIMEZONE == "" {
+		Cfg.TODO_TIMEZONE = "Local"
+	}
+	loc, err := time.LoadLocation(Cfg.TODO_TIMEZONE)
+	if err != nil {
+		loger.L.Error("Unknown timezone in TODO_TIMEZONE", "tz", Cfg.TODO_TIMEZONE, "err", err)
+		os.Exit(1)
+	}
+	Cfg.Location = loc
+
+	Cfg.TODO_TRASH_RETENTION = os.Getenv("TODO_TRASH_RETENTION")
+	if Cfg.TODO_TRASH_RETENTION == "" {
+		Cfg.TODO_TRASH_RETENTION = defaultTrashRetention
+	}
+	retention, err := time.ParseDuration(Cfg.TODO_TRASH_RETENTION)
+	if err != nil || retention < 0 {
+		loger.L.Error("Invalid duration in TODO_TRASH_RETENTION", "retention", Cfg.TODO_TRASH_RETENTION, "err", err)
+		os.Exit(1)
+	}
+	Cfg.TrashRetention = retention
+
+	Cfg.TODO_UNDO_WINDOW = os.Getenv("TODO_UNDO_WINDOW")
+	if Cfg.TODO_UNDO_WINDOW == "" {
+		Cfg.TODO_UNDO_WINDOW = defaultUndoWindow
+	}
+	window, err := time.ParseDuration(Cfg.TODO_UNDO_WINDOW)
+	if err != nil || window < 0 {
+		loger.L.Error("Invalid duration in TODO_UNDO_WINDOW", "window", Cfg.TODO_UNDO_WINDOW, "err", err)
+		os.Exit(1)
+	}
+	Cfg.UndoWindow = window
+
+	Cfg.TODO_SUBTASKS_BLOCK_DONE = os.Getenv("TODO_SUBTASKS_BLOCK_DONE")
+	if Cfg.TODO_SUBTASKS_BLOCK_DONE != "" {
+		block, err := strconv.ParseBool(Cfg.TODO_SUBTASKS_BLOCK_DONE)
+		if err != nil {
+			loger.L.Error("Invalid boolean in TODO_SUBTASKS_BLOCK_DONE", "value", Cfg.TODO_SUBTASKS_BLOCK_DONE, "err", err)
+			os.Exit(1)
+		}
+		Cfg.BlockOpenSubtasks = block
+	}
 }
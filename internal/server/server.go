@@ -11,6 +11,7 @@ import (
 
 	"github.com/NarthurN/TODO-API-web/internal/config"
 	"github.com/NarthurN/TODO-API-web/pkg/api"
+	"github.com/NarthurN/TODO-API-web/pkg/calendar"
 	"github.com/NarthurN/TODO-API-web/pkg/loger"
 	"github.com/NarthurN/TODO-API-web/pkg/middleware"
 )
@@ -51,11 +52,31 @@ func (s *Server) Run() error {
 
 type storage interface {
 	AddTask(task api.Task) (int64, error)
-	GetTasks(limit int, search string) ([]api.Task, error)
+	GetTasks(limit int, filter api.TaskFilter, order api.TaskOrder) ([]api.Task, error)
 	GetTask(id string) (*api.Task, error)
 	UpdateTask(task *api.Task) error
 	DeleteTask(id string) error
-	UpdateDate(next string, id string) error
+	CompleteTask(c api.Completion, next *api.Task) error
+	GetTrash(limit int) ([]api.Task, error)
+	RestoreTask(id string) error
+	GetLastCompletion(taskID string) (*api.Completion, error)
+	UndoCompletion(c *api.Completion) error
+	GetHistory(limit int, from, to, taskID string) ([]api.Completion, error)
+	SetSubtaskDone(id string, done bool) (string, error)
+	AddDependency(taskID, blockerID string) error
+	DeleteDependency(taskID, blockerID string) error
+	GetLists() ([]api.List, error)
+	GetList(id string) (*api.List, error)
+	AddList(name string) (int64, error)
+	RenameList(id, name string) error
+	DeleteList(id string) error
+	GetTags() ([]api.Tag, error)
+	AddTag(name string) (int64, error)
+	RenameTag(id, name string) error
+	DeleteTag(id string) error
+	GetHolidays() ([]calendar.Day, error)
+	AddHoliday(day calendar.Day) error
+	DeleteHoliday(date string) error
 	Close() error
 }
 
@@ -73,12 +94,27 @@ func New(db storage) *Server {
 
 func NewMux(db storage) http.Handler {
 	mux := http.NewServeMux()
-	api := api.New(db)
+	api := api.New(db, config.Cfg.Location)
+	api.UndoWindow = config.Cfg.UndoWindow
+	api.BlockOpenSubtasks = config.Cfg.BlockOpenSubtasks
 
 	mux.Handle(`GET /`, http.FileServer(http.Dir(`./web`)))
 	// "api/nextdate?now=20240126&date=20240126&repeat=y"
 	mux.Handle("GET /api/nextdate", api.NextDayHandler())
-
+	// /api/occurrences?date=20240126&repeat=d 7&count=5 или &from=20240201&to=20240301
+	mux.Handle("GET /api/occurrences", api.OccurrencesHandle())
+	// /api/rrule?repeat=w 1,3 -> {"repeat":"w 1,3","rrule":"FREQ=WEEKLY;BYDAY=MO,WE"}
+	mux.Handle("GET /api/rrule", api.RRuleHandle())
+	// /api/describe?repeat=w 1,3 -> {"description":"каждую неделю по понедельникам и средам"}
+	mux.Handle("GET /api/describe", api.DescribeHandle())
+	// /api/validate?repeat=m 1,32 -> {"valid":false,"code":"invalid_month_day","token":"32","position":5,...}
+	mux.Handle("GET /api/validate", api.ValidateHandle())
+	// /api/parse?text=каждый вторник -> {"repeat":"w 2","description":"каждую неделю по вторникам"}
+	mux.Handle("GET /api/parse", api.ParsePhraseHandle())
+
+	// /api/tasks?list=<идентификатор списка>&search=<строка или #метка>&tags=<метки через запятую>&match=<all|any>
+	// &unblocked=<true|false>
+	// &sort=<date|priority|title|created>&order=<asc|desc>
 	mux.Handle("GET /api/tasks", middleware.Auth(api.GetTasksHandle()))
 	mux.Handle("POST /api/task", middleware.Auth(api.AddTaskHandle()))
 
@@ -87,8 +123,44 @@ func NewMux(db storage) http.Handler {
 	mux.Handle("PUT /api/task", middleware.Auth(api.ChangeTaskHandle()))
 	// /api/task/done?id=<идентификатор>
 	mux.Handle("POST /api/task/done", middleware.Auth(api.DeleteOrRepeatHandle()))
+	// /api/task/undo?id=<идентификатор> — отмена последнего выполнения в течение TODO_UNDO_WINDOW
+	mux.Handle("POST /api/task/undo", middleware.Auth(api.UndoDoneHandle()))
+	// /api/history?from=<YYYYMMDD>&to=<YYYYMMDD>&id=<идентификатор>
+	mux.Handle("GET /api/history", middleware.Auth(api.HistoryHandle()))
 	// /api/task?id=<идентификатор>
 	mux.Handle("DELETE /api/task", middleware.Auth(api.DeleteTaskHandle()))
+	// удалённые задачи лежат в корзине TODO_TRASH_RETENTION, затем удаляются окончательно
+	mux.Handle("GET /api/trash", middleware.Auth(api.TrashHandle()))
+	// /api/task/restore?id=<идентификатор>
+	mux.Handle("POST /api/task/restore", middleware.Auth(api.RestoreTaskHandle()))
+	// /api/task/dependency?id=<идентификатор>&blocker=<идентификатор блокирующей задачи>
+	mux.Handle("POST /api/task/dependency", middleware.Auth(api.AddDependencyHandle()))
+	mux.Handle("DELETE /api/task/dependency", middleware.Auth(api.DeleteDependencyHandle()))
+	// /api/subtask/done?id=<идентификатор подзадачи>&done=<true|false>
+	mux.Handle("POST /api/subtask/done", middleware.Auth(api.SubtaskDoneHandle()))
+	// /api/task/exdate?id=<идентификатор>&date=<YYYYMMDD>
+	mux.Handle("POST /api/task/exdate", middleware.Auth(api.AddExdateHandle()))
+	mux.Handle("DELETE /api/task/exdate", middleware.Auth(api.DeleteExdateHandle()))
+
+	// списки задач; список 1 — «Входящие»
+	mux.Handle("GET /api/lists", middleware.Auth(api.GetListsHandle()))
+	mux.Handle("POST /api/list", middleware.Auth(api.AddListHandle()))
+	mux.Handle("PUT /api/list", middleware.Auth(api.RenameListHandle()))
+	// /api/list?id=<идентификатор>
+	mux.Handle("DELETE /api/list", middleware.Auth(api.DeleteListHandle()))
+
+	// метки задач
+	mux.Handle("GET /api/tags", middleware.Auth(api.GetTagsHandle()))
+	mux.Handle("POST /api/tag", middleware.Auth(api.AddTagHandle()))
+	mux.Handle("PUT /api/tag", middleware.Auth(api.RenameTagHandle()))
+	// /api/tag?id=<идентификатор>
+	mux.Handle("DELETE /api/tag", middleware.Auth(api.DeleteTagHandle()))
+
+	// производственный календарь для правил с рабочими днями
+	mux.Handle("GET /api/holidays", middleware.Auth(api.GetHolidaysHandle()))
+	mux.Handle("POST /api/holiday", middleware.Auth(api.AddHolidayHandle()))
+	// /api/holiday?date=<YYYYMMDD>
+	mux.Handle("DELETE /api/holiday", middleware.Auth(api.DeleteHolidayHandle()))
 
 	//аутентификация
 	mux.Handle("POST /api/signin", api.SignInHandle())
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// businessDay — повторение через N рабочих дней: "b 3".
+	businessDay = "b"
+
+	// Модификаторы переноса с нерабочего дня, записываются последним
+	// токеном правила: "d 7 >", "m 1,15 <", "y >".
+	shiftForward  = ">"
+	shiftBackward = "<"
+
+	// maxShiftSteps ограничивает поиск даты, которая после переноса остаётся в будущем.
+	maxShiftSteps = 1000
+)
+
+// shiftable — правила, к которым можно добавить модификатор переноса.
+var shiftable = []string{day, month, year, nthWeekday}
+
+// cutShift отделяет модификатор переноса от правила.
+func cutShift(repeat string) (string, string, bool) {
+	for _, shift := range []string{shiftForward, shiftBackward} {
+		if base, ok := strings.CutSuffix(repeat, " "+shift); ok {
+			return base, shift, true
+		}
+	}
+	return repeat, "", false
+}
+
+func parseBusinessDays(repeatSlice []string) (int, error) {
+	if len(repeatSlice) != 2 {
+		return 0, ErrInvalidFormatInBusinessDay
+	}
+	days, err := strconv.Atoi(repeatSlice[1])
+	if err != nil || days < 1 {
+		return 0, ErrInvalidFormatInBusinessDay
+	}
+	if days > 400 {
+		return 0, ErrManyDays
+	}
+	return days, nil
+}
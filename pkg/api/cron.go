@@ -0,0 +1,241 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kindCron — вид правила, записанного cron-выражением из 5 полей.
+const kindCron = "cron"
+
+var ErrInvalidCron error = errors.New("cron expression is incorrect")
+
+// cronField — допустимые значения одного поля cron-выражения.
+type cronField struct {
+	name     string
+	min, max int
+	// names — названия значений (JAN, MON), индекс равен значению.
+	names []string
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12,
+		names: []string{"", "JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+	// 7 — тоже воскресенье
+	{name: "day of week", min: 0, max: 7,
+		names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}},
+}
+
+// cronRule — cron-выражение "минута час день месяц день_недели". Задачи
+// планируются по дням, поэтому дата считается по последним трём полям,
+// а минута и час только задают время начала задачи.
+type cronRule struct {
+	minutes  []int
+	hours    []int
+	days     [32]bool
+	months   [13]bool
+	weekdays [7]bool
+	// anyDay и anyWeekday — поле задано звёздочкой. Если ограничены оба поля,
+	// дата подходит, когда совпадает любое из них, как в cron.
+	anyDay, anyWeekday bool
+}
+
+// IsCron сообщает, записано ли правило повторения cron-выражением:
+// 5 полей, первое начинается с цифры или звёздочки.
+func IsCron(repeat string) bool {
+	fields := strings.Fields(repeat)
+	return len(fields) == len(cronFields) && strings.ContainsAny(fields[0][:1], "0123456789*")
+}
+
+func parseCron(repeat string) (cronRule, error) {
+	fields := strings.Fields(repeat)
+	if len(fields) != len(cronFields) {
+		return cronRule{}, fmt.Errorf("%w: expected %d fields", ErrInvalidCron, len(cronFields))
+	}
+
+	var c cronRule
+	values := make([][]int, len(fields))
+	for i, field := range fields {
+		var err error
+		values[i], err = cronFields[i].parse(field)
+		if err != nil {
+			return cronRule{}, err
+		}
+	}
+
+	c.minutes, c.hours = values[0], values[1]
+	for _, d := range values[2] {
+		c.days[d] = true
+	}
+	for _, m := range values[3] {
+		c.months[m] = true
+	}
+	for _, wd := range values[4] {
+		c.weekdays[wd%7] = true
+	}
+	c.anyDay = strings.HasPrefix(fields[2], "*")
+	c.anyWeekday = strings.HasPrefix(fields[4], "*")
+
+	return c, nil
+}
+
+// parse разбирает поле: "*", "5", "1-5", "*/15", "10-40/10", "MON-FRI" и их списки через запятую.
+func (f cronField) parse(field string) ([]int, error) {
+	seen := make([]bool, f.max+1)
+	for _, item := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step < 1 || step > f.max {
+				return nil, fmt.Errorf("%w: %s step %q", ErrInvalidCron, f.name, stepStr)
+			}
+		}
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(loStr); err != nil {
+				return nil, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(hiStr); err != nil {
+					return nil, err
+				}
+			} else if hasStep {
+				// "10/15" — с 10 до конца поля с шагом 15
+				hi = f.max
+			}
+			if hi < lo {
+				return nil, fmt.Errorf("%w: %s range %q", ErrInvalidCron, f.name, rng)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			seen[v] = true
+		}
+	}
+
+	var values []int
+	for v, ok := range seen {
+		if ok {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%w: %s value %q", ErrInvalidCron, f.name, s)
+	}
+	return v, nil
+}
+
+// matches сообщает, подходит ли день t под поля дня, месяца и дня недели.
+func (c cronRule) matches(t time.Time) bool {
+	if !c.months[t.Month()] {
+		return false
+	}
+	day, weekday := c.days[t.Day()], c.weekdays[t.Weekday()]
+	if c.anyDay || c.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// next возвращает первый подходящий день позже start и сегодняшнего дня.
+// Месяцы, не подходящие под поле месяца, пропускаются целиком.
+func (c cronRule) next(now, start time.Time) (time.Time, error) {
+	from := start
+	if today := dateOf(now, start.Location()); today.After(from) {
+		from = today
+	}
+	first := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, start.Location())
+
+	for i := 0; i < maxMonthSearch; i++ {
+		m := first.AddDate(0, i, 0)
+		if !c.months[m.Month()] {
+			continue
+		}
+		for t := m; t.Month() == m.Month(); t = t.AddDate(0, 0, 1) {
+			if t.After(from) && c.matches(t) {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, ErrNoOccurrence
+}
+
+// timeOfDay возвращает время начала "HH:MM", если минута и час заданы одним значением.
+func (c cronRule) timeOfDay() (string, bool) {
+	if len(c.minutes) != 1 || len(c.hours) != 1 {
+		return "", false
+	}
+	return fmt.Sprintf("%02d:%02d", c.hours[0], c.minutes[0]), true
+}
+
+// rrules переводит дни cron-выражения в правила RRULE для описания словами.
+// Если ограничены и день месяца, и день недели, правил два: подходит любое.
+// Если же одно из полей задано шагом от звёздочки ("*/10"), дни недели
+// возвращаются в onlyOn: дата должна подходить под оба поля.
+func (c cronRule) rrules() (rules []rrule, onlyOn []weekdayNum) {
+	var months []int
+	for m := 1; m <= 12; m++ {
+		if c.months[m] {
+			months = append(months, m)
+		}
+	}
+	if len(months) == 12 {
+		months = nil
+	}
+
+	var byMonthDay []int
+	for d := 1; d <= 31; d++ {
+		if c.days[d] {
+			byMonthDay = append(byMonthDay, d)
+		}
+	}
+	var byDay []weekdayNum
+	// неделя в описании начинается с понедельника
+	for i := 1; i <= 7; i++ {
+		if wd := time.Weekday(i % 7); c.weekdays[wd] {
+			byDay = append(byDay, weekdayNum{weekday: wd})
+		}
+	}
+
+	daily := rrule{freq: freqDaily, interval: 1, byMonth: months}
+	monthly := rrule{freq: freqMonthly, interval: 1, byMonthDay: byMonthDay, byMonth: months}
+	weekly := rrule{freq: freqWeekly, interval: 1, byDay: byDay, byMonth: months}
+	allDays, allWeekdays := len(byMonthDay) == 31, len(byDay) == 7
+	switch {
+	case allDays && allWeekdays:
+		return []rrule{daily}, nil
+	case c.anyDay || c.anyWeekday:
+		if allWeekdays {
+			return []rrule{monthly}, nil
+		}
+		if allDays {
+			return []rrule{weekly}, nil
+		}
+		return []rrule{monthly}, byDay
+	case allDays || allWeekdays:
+		return []rrule{daily}, nil
+	}
+	return []rrule{monthly, weekly}, nil
+}
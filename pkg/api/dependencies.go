@@ -0,0 +1,10 @@
+package api
+
+import "errors"
+
+var (
+	ErrSelfDependency     error = errors.New("task cannot block itself")
+	ErrDependencyCycle    error = errors.New("dependency creates a cycle")
+	ErrBlockerNotFound    error = errors.New("blocking task not found")
+	ErrDependencyNotFound error = errors.New("task is not blocked by this task")
+)
@@ -0,0 +1,568 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	LangRu = "ru"
+	LangEn = "en"
+)
+
+var (
+	ruWeekdaysDative = []string{"воскресеньям", "понедельникам", "вторникам", "средам", "четвергам", "пятницам", "субботам"}
+	ruWeekdaysAccus  = []string{"воскресенье", "понедельник", "вторник", "среду", "четверг", "пятницу", "субботу"}
+	ruMonthsGenitive = []string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"}
+	ruMonthsPrepos   = []string{"январе", "феврале", "марте", "апреле", "мае", "июне", "июле", "августе", "сентябре", "октябре", "ноябре", "декабре"}
+
+	// ruOrdinals — порядковые числительные в винительном падеже по родам
+	// дня недели: мужской (понедельник), женский (среда), средний (воскресенье).
+	ruOrdinals = map[int][3]string{
+		1:  {"первый", "первую", "первое"},
+		2:  {"второй", "вторую", "второе"},
+		3:  {"третий", "третью", "третье"},
+		4:  {"четвёртый", "четвёртую", "четвёртое"},
+		5:  {"пятый", "пятую", "пятое"},
+		-1: {"последний", "последнюю", "последнее"},
+		-2: {"предпоследний", "предпоследнюю", "предпоследнее"},
+	}
+	// ruWeekdayGender — индекс рода из ruOrdinals для каждого time.Weekday.
+	ruWeekdayGender = []int{2, 0, 0, 1, 0, 1, 1}
+
+	enOrdinalWords = map[int]string{1: "first", 2: "second", 3: "third", 4: "fourth", 5: "fifth", -1: "last", -2: "second to last"}
+)
+
+// PreferredLanguage выбирает язык описания по заголовку Accept-Language.
+// Поддерживаются русский и английский, по умолчанию — русский.
+func PreferredLanguage(acceptLanguage string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if (primary == LangRu || primary == LangEn) && q > 0 {
+			candidates = append(candidates, candidate{lang: primary, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	if len(candidates) == 0 {
+		return LangRu
+	}
+	return candidates[0].lang
+}
+
+// DescribeSeries описывает правило повторения вместе с условиями окончания серии.
+func DescribeSeries(s Series, lang string) (string, error) {
+	text, err := Describe(s.Repeat, lang)
+	if err != nil {
+		return "", err
+	}
+
+	if s.Until != "" {
+		until, err := time.Parse(Layout, s.Until)
+		if err != nil {
+			return "", ErrInvalidUntil
+		}
+		if lang == LangEn {
+			text += ", until " + until.Format("January 2, 2006")
+		} else {
+			text += ", до " + ruDate(until)
+		}
+	}
+	if s.Remaining > 0 {
+		if lang == LangEn {
+			text += fmt.Sprintf(", %d %s left", s.Remaining, enPlural(s.Remaining, "occurrence"))
+		} else {
+			text += fmt.Sprintf(", осталось повторений: %d", s.Remaining)
+		}
+	}
+	if len(s.Exdates) > 0 {
+		dates := make([]string, 0, len(s.Exdates))
+		for _, exdate := range s.Exdates {
+			t, err := time.Parse(Layout, exdate)
+			if err != nil {
+				return "", ErrInvalidExdate
+			}
+			if lang == LangEn {
+				dates = append(dates, t.Format("January 2, 2006"))
+			} else {
+				dates = append(dates, ruDate(t))
+			}
+		}
+		if lang == LangEn {
+			text += ", except " + joinEn(dates)
+		} else {
+			text += ", кроме " + joinRu(dates)
+		}
+	}
+
+	return text, nil
+}
+
+// Describe возвращает описание правила повторения на языке lang (LangRu или LangEn),
+// например "1-го числа и в последний день февраля и августа" для "m 1,-1 2,8".
+func Describe(repeat, lang string) (string, error) {
+	if repeat == "" {
+		return "", ErrInvalidRepeatParameter
+	}
+
+	base, shift, shifted := cutShift(repeat)
+	repeatSlice := strings.Split(base, " ")
+
+	var text string
+	switch {
+	case IsRRule(base):
+		r, err := parseRRule(base)
+		if err != nil {
+			return "", err
+		}
+		text = describeRRule(r, lang)
+	case IsCron(base):
+		c, err := parseCron(base)
+		if err != nil {
+			return "", err
+		}
+		text = describeCron(c, lang)
+	case base == spaced:
+		if lang == LangEn {
+			text = "spaced repetition: the interval depends on how easy the task was"
+		} else {
+			text = "интервальное повторение: интервал зависит от оценки выполнения"
+		}
+	case repeatSlice[0] == businessDay:
+		days, err := parseBusinessDays(repeatSlice)
+		if err != nil {
+			return "", err
+		}
+		if lang == LangEn {
+			text = "every " + enEvery(days, "working day")
+		} else {
+			text = ruEvery(days, "рабочий день", "рабочих дня", "рабочих дней")
+		}
+	default:
+		r, err := parseShort(base)
+		if err != nil {
+			return "", err
+		}
+		text = describeRRule(r, lang)
+	}
+
+	if shifted && !slices.Contains(shiftable, repeatSlice[0]) {
+		return "", ErrUnknownFormat
+	}
+	if shifted {
+		switch {
+		case lang == LangEn && shift == shiftForward:
+			text += ", moved to the next working day if it falls on a day off"
+		case lang == LangEn:
+			text += ", moved to the previous working day if it falls on a day off"
+		case shift == shiftForward:
+			text += ", с переносом на следующий рабочий день"
+		default:
+			text += ", с переносом на предыдущий рабочий день"
+		}
+	}
+
+	return text, nil
+}
+
+func describeRRule(r rrule, lang string) string {
+	var text string
+	if lang == LangEn {
+		text = describeRRuleEn(r)
+	} else {
+		text = describeRRuleRu(r)
+	}
+
+	if !r.until.IsZero() {
+		if lang == LangEn {
+			text += ", until " + r.until.Format("January 2, 2006")
+		} else {
+			text += ", до " + ruDate(r.until)
+		}
+	}
+	if r.count > 0 {
+		if lang == LangEn {
+			text += fmt.Sprintf(", %d %s", r.count, enPlural(r.count, "time"))
+		} else {
+			text += fmt.Sprintf(", %d %s", r.count, ruPlural(r.count, "раз", "раза", "раз"))
+		}
+	}
+	return text
+}
+
+// describeCron описывает дни cron-выражения теми же словами, что и RRULE,
+// и добавляет время начала, если оно одно.
+func describeCron(c cronRule, lang string) string {
+	rules, onlyOn := c.rrules()
+	var days []string
+	for _, r := range rules {
+		days = append(days, describeRRule(r, lang))
+	}
+
+	if lang == LangEn {
+		text := strings.Join(days, " or ")
+		if len(onlyOn) > 0 {
+			text += ", only on " + enWeekdays(onlyOn)
+		}
+		if at, ok := c.timeOfDay(); ok {
+			text += " at " + at
+		}
+		return text
+	}
+	text := strings.Join(days, " или ")
+	if len(onlyOn) > 0 {
+		text += ", только " + ruWeekdays(onlyOn)
+	}
+	if at, ok := c.timeOfDay(); ok {
+		text += " в " + at
+	}
+	return text
+}
+
+func describeRRuleRu(r rrule) string {
+	var parts []string
+	switch r.freq {
+	case freqDaily:
+		parts = append(parts, ruEvery(r.interval, "день", "дня", "дней"))
+		if len(r.byMonthDay) > 0 {
+			parts = append(parts, ruMonthDays(r.byMonthDay))
+		}
+		if len(r.byDay) > 0 {
+			parts = append(parts, ruWeekdays(r.byDay))
+		}
+		if len(r.byMonth) > 0 {
+			parts = append(parts, "в "+joinRu(monthNames(r.byMonth, ruMonthsPrepos)))
+		}
+		return strings.Join(parts, ", ")
+	case freqWeekly:
+		text := ruEveryFem(r.interval, "неделю", "недели", "недель")
+		if len(r.byDay) > 0 {
+			text += " " + ruWeekdays(r.byDay)
+		}
+		if len(r.byMonth) > 0 {
+			text += ", в " + joinRu(monthNames(r.byMonth, ruMonthsPrepos))
+		}
+		return text
+	case freqMonthly:
+		days := ruPeriodDays(r)
+		if r.interval == 1 {
+			if len(r.byMonth) > 0 {
+				return days + " " + joinRu(monthNames(r.byMonth, ruMonthsGenitive))
+			}
+			return days + " каждого месяца"
+		}
+		text := ruEvery(r.interval, "месяц", "месяца", "месяцев") + " " + days
+		if len(r.byMonth) > 0 {
+			text += ", в " + joinRu(monthNames(r.byMonth, ruMonthsPrepos))
+		}
+		return text
+	default:
+		text := ruEvery(r.interval, "год", "года", "лет")
+		switch {
+		case len(r.byMonth) > 0:
+			text += " " + ruPeriodDays(r) + " " + joinRu(monthNames(r.byMonth, ruMonthsGenitive))
+		case len(r.byMonthDay) > 0:
+			text += " " + ruPeriodDays(r) + " каждого месяца"
+		case len(r.byDay) > 0:
+			text += " " + ruNthWeekdays(r.byDay) + " года"
+		}
+		return text
+	}
+}
+
+func describeRRuleEn(r rrule) string {
+	var parts []string
+	switch r.freq {
+	case freqDaily:
+		parts = append(parts, "every "+enEvery(r.interval, "day"))
+		if len(r.byMonthDay) > 0 {
+			parts = append(parts, "on the "+enMonthDays(r.byMonthDay)+" day of the month")
+		}
+		if len(r.byDay) > 0 {
+			parts = append(parts, "on "+enWeekdays(r.byDay))
+		}
+		if len(r.byMonth) > 0 {
+			parts = append(parts, "in "+joinEn(monthNames(r.byMonth, nil)))
+		}
+		return strings.Join(parts, ", ")
+	case freqWeekly:
+		text := "every " + enEvery(r.interval, "week")
+		if len(r.byDay) > 0 {
+			text += " on " + enWeekdays(r.byDay)
+		}
+		if len(r.byMonth) > 0 {
+			text += ", in " + joinEn(monthNames(r.byMonth, nil))
+		}
+		return text
+	case freqMonthly:
+		days := enPeriodDays(r)
+		if r.interval == 1 {
+			if len(r.byMonth) > 0 {
+				return days + " of " + joinEn(monthNames(r.byMonth, nil))
+			}
+			return days + " of every month"
+		}
+		text := "every " + enEvery(r.interval, "month") + " " + days
+		if len(r.byMonth) > 0 {
+			text += ", in " + joinEn(monthNames(r.byMonth, nil))
+		}
+		return text
+	default:
+		text := "every " + enEvery(r.interval, "year")
+		switch {
+		case len(r.byMonth) > 0:
+			text += " " + enPeriodDays(r) + " of " + joinEn(monthNames(r.byMonth, nil))
+		case len(r.byMonthDay) > 0:
+			text += " " + enPeriodDays(r) + " of every month"
+		case len(r.byDay) > 0:
+			text += " on the " + enNthWeekdays(r.byDay) + " of the year"
+		}
+		return text
+	}
+}
+
+// ruPeriodDays описывает дни внутри месяца: числа, n-е дни недели или тот же день, что и дата задачи.
+func ruPeriodDays(r rrule) string {
+	var parts []string
+	if len(r.byMonthDay) > 0 {
+		parts = append(parts, ruMonthDays(r.byMonthDay))
+	}
+	if len(r.byDay) > 0 {
+		parts = append(parts, ruNthWeekdays(r.byDay))
+	}
+	if len(parts) == 0 {
+		return "в тот же день"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func enPeriodDays(r rrule) string {
+	var parts []string
+	if len(r.byMonthDay) > 0 {
+		parts = append(parts, "on the "+enMonthDays(r.byMonthDay)+" day")
+	}
+	if len(r.byDay) > 0 {
+		parts = append(parts, "on the "+enNthWeekdays(r.byDay))
+	}
+	if len(parts) == 0 {
+		return "on the same day"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ruMonthDays: [1, 15, -1] -> "1-го и 15-го числа и в последний день".
+func ruMonthDays(days []int) string {
+	var numbers, fromEnd []string
+	for _, d := range days {
+		switch {
+		case d > 0:
+			numbers = append(numbers, strconv.Itoa(d)+"-го")
+		case d == -1:
+			fromEnd = append(fromEnd, "в последний день")
+		case d == -2:
+			fromEnd = append(fromEnd, "в предпоследний день")
+		default:
+			fromEnd = append(fromEnd, fmt.Sprintf("за %d %s до конца месяца", -d-1, ruPlural(-d-1, "день", "дня", "дней")))
+		}
+	}
+	var parts []string
+	if len(numbers) > 0 {
+		parts = append(parts, joinRu(numbers)+" числа")
+	}
+	return joinRu(append(parts, fromEnd...))
+}
+
+// enMonthDays: [1, 15, -1] -> "1st, 15th and last".
+func enMonthDays(days []int) string {
+	var items []string
+	for _, d := range days {
+		switch {
+		case d > 0:
+			items = append(items, enOrdinal(d))
+		case d == -1:
+			items = append(items, "last")
+		default:
+			items = append(items, enOrdinal(-d)+" to last")
+		}
+	}
+	return joinEn(items)
+}
+
+// ruWeekdays описывает дни недели без номера: "по понедельникам и средам".
+// Если у дней есть номер (2TU), используется ruNthWeekdays.
+func ruWeekdays(days []weekdayNum) string {
+	var names []string
+	for _, d := range days {
+		if d.n != 0 {
+			return ruNthWeekdays(days)
+		}
+		names = append(names, ruWeekdaysDative[d.weekday])
+	}
+	return "по " + joinRu(names)
+}
+
+func enWeekdays(days []weekdayNum) string {
+	var names []string
+	for _, d := range days {
+		names = append(names, time.Weekday(d.weekday).String())
+	}
+	return joinEn(names)
+}
+
+// ruNthWeekdays: [2TU, -1FR] -> "во второй вторник и в последнюю пятницу".
+func ruNthWeekdays(days []weekdayNum) string {
+	var items []string
+	for _, d := range days {
+		if d.n == 0 {
+			items = append(items, "по "+ruWeekdaysDative[d.weekday])
+			continue
+		}
+		gender := ruWeekdayGender[d.weekday]
+		ordinal, ok := ruOrdinals[d.n]
+		word := ordinal[gender]
+		if !ok {
+			word = strconv.Itoa(d.n) + "-й"
+			if d.n < 0 {
+				word = strconv.Itoa(-d.n) + "-й с конца"
+			}
+		}
+		prep := "в"
+		if strings.HasPrefix(word, "втор") {
+			prep = "во"
+		}
+		items = append(items, prep+" "+word+" "+ruWeekdaysAccus[d.weekday])
+	}
+	return joinRu(items)
+}
+
+// enNthWeekdays: [2TU, -1FR] -> "second Tuesday and last Friday".
+func enNthWeekdays(days []weekdayNum) string {
+	var items []string
+	for _, d := range days {
+		name := d.weekday.String()
+		word, ok := enOrdinalWords[d.n]
+		switch {
+		case d.n == 0:
+			items = append(items, "every "+name)
+		case ok:
+			items = append(items, word+" "+name)
+		case d.n > 0:
+			items = append(items, enOrdinal(d.n)+" "+name)
+		default:
+			items = append(items, enOrdinal(-d.n)+" to last "+name)
+		}
+	}
+	return joinEn(items)
+}
+
+func monthNames(months []int, names []string) []string {
+	items := make([]string, 0, len(months))
+	for _, m := range months {
+		if names == nil {
+			items = append(items, time.Month(m).String())
+		} else {
+			items = append(items, names[m-1])
+		}
+	}
+	return items
+}
+
+// ruEvery: 1 -> "каждый день", 2 -> "каждые 2 дня", 21 -> "каждый 21 день".
+func ruEvery(n int, one, few, many string) string {
+	switch {
+	case n == 1:
+		return "каждый " + one
+	case n%10 == 1 && n%100 != 11:
+		return fmt.Sprintf("каждый %d %s", n, one)
+	default:
+		return fmt.Sprintf("каждые %d %s", n, ruPlural(n, one, few, many))
+	}
+}
+
+// ruEveryFem — ruEvery для существительных женского рода: "каждую неделю".
+func ruEveryFem(n int, one, few, many string) string {
+	switch {
+	case n == 1:
+		return "каждую " + one
+	case n%10 == 1 && n%100 != 11:
+		return fmt.Sprintf("каждую %d %s", n, one)
+	default:
+		return fmt.Sprintf("каждые %d %s", n, ruPlural(n, one, few, many))
+	}
+}
+
+func ruPlural(n int, one, few, many string) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return one
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return few
+	default:
+		return many
+	}
+}
+
+// enEvery: 1 -> "day", 3 -> "3 days".
+func enEvery(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+	return fmt.Sprintf("%d %s", n, enPlural(n, noun))
+}
+
+func enPlural(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+	return noun + "s"
+}
+
+func enOrdinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+func ruDate(t time.Time) string {
+	return fmt.Sprintf("%d %s %d", t.Day(), ruMonthsGenitive[t.Month()-1], t.Year())
+}
+
+func joinRu(items []string) string {
+	return joinWith(items, " и ")
+}
+
+func joinEn(items []string) string {
+	return joinWith(items, " and ")
+}
+
+func joinWith(items []string, last string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + last + items[len(items)-1]
+}
@@ -8,10 +8,12 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/NarthurN/TODO-API-web/pkg/calendar"
 	"github.com/NarthurN/TODO-API-web/pkg/loger"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -22,21 +24,50 @@ var ErrInvalidDate error = errors.New("date is in invalid format")
 var ErrIncorrectPassword error = errors.New("неверный пароль")
 
 type Storage interface {
-	GetTasks(limit int, search string) ([]Task, error)
+	GetTasks(limit int, filter TaskFilter, order TaskOrder) ([]Task, error)
 	AddTask(task Task) (int64, error)
 	GetTask(id string) (*Task, error)
 	UpdateTask(task *Task) error
 	DeleteTask(id string) error
-	UpdateDate(next string, id string) error
+	CompleteTask(c Completion, next *Task) error
+	GetTrash(limit int) ([]Task, error)
+	RestoreTask(id string) error
+	GetLastCompletion(taskID string) (*Completion, error)
+	UndoCompletion(c *Completion) error
+	GetHistory(limit int, from, to, taskID string) ([]Completion, error)
+	SetSubtaskDone(id string, done bool) (string, error)
+	AddDependency(taskID, blockerID string) error
+	DeleteDependency(taskID, blockerID string) error
+	GetLists() ([]List, error)
+	GetList(id string) (*List, error)
+	AddList(name string) (int64, error)
+	RenameList(id, name string) error
+	DeleteList(id string) error
+	GetTags() ([]Tag, error)
+	AddTag(name string) (int64, error)
+	RenameTag(id, name string) error
+	DeleteTag(id string) error
+	GetHolidays() ([]calendar.Day, error)
+	AddHoliday(day calendar.Day) error
+	DeleteHoliday(date string) error
 	Close() error
 }
 
 type Api struct {
 	Storage Storage
+	// Location — часовой пояс по умолчанию, в котором считаются «сегодня» и следующие даты.
+	Location *time.Location
+	// UndoWindow — сколько после выполнения задачи его можно отменить; 0 — отмена недоступна.
+	UndoWindow time.Duration
+	// BlockOpenSubtasks запрещает выполнять задачу с невыполненными подзадачами.
+	BlockOpenSubtasks bool
 }
 
-func New(db Storage) *Api {
-	return &Api{Storage: db}
+func New(db Storage, loc *time.Location) *Api {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Api{Storage: db, Location: loc}
 }
 
 func (h *Api) NextDayHandler() http.Handler {
@@ -49,12 +80,17 @@ func (h *Api) NextDayHandler() http.Handler {
 		loger.L.Info("FormValue:", "repeat", repeat)
 		var now time.Time
 		if nowStr == "" {
-			now = time.Now().UTC()
+			loc, err := h.location(r)
+			if err != nil {
+				SendRuleError(w, err)
+				return
+			}
+			now = wallClock(time.Now(), loc)
 		} else {
 			var err error
 			now, err = time.Parse(Layout, nowStr)
 			if err != nil {
-				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				SendRuleError(w, NewRuleError("now", "", ErrInvalidDate))
 				return
 			}
 		}
@@ -64,9 +100,15 @@ func (h *Api) NextDayHandler() http.Handler {
 			return
 		}
 
-		newDate, err := NextDate(now, dateStr, repeat)
+		series, err := seriesFromRequest(r, repeat)
+		if err != nil {
+			SendRuleError(w, NewRuleError("", repeat, err))
+			return
+		}
+
+		newDate, err := series.Next(now, dateStr)
 		if err != nil {
-			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			SendRuleError(w, NewRuleError("", repeat, err))
 			return
 		}
 
@@ -75,28 +117,257 @@ func (h *Api) NextDayHandler() http.Handler {
 	})
 }
 
+// OccurrencesHandle возвращает серию дат правила повторения:
+// /api/occurrences?date=20240126&repeat=w 1,5&count=10 или &from=20240201&to=20240301.
+func (h *Api) OccurrencesHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dateStr := r.FormValue("date")
+		repeat := r.FormValue("repeat")
+		if dateStr == "" || repeat == "" {
+			loger.L.Error("missing parameters", "date", dateStr, "repeat", repeat)
+			SendErrorResponse(w, "Missing parameters: date or repeat")
+			return
+		}
+
+		var count int
+		if countStr := r.FormValue("count"); countStr != "" {
+			var err error
+			count, err = strconv.Atoi(countStr)
+			if err != nil {
+				loger.L.Error("strconv.Atoi:", "count", countStr, "err", err)
+				SendErrorResponse(w, ErrInvalidCount.Error())
+				return
+			}
+		}
+
+		var from, to time.Time
+		for param, t := range map[string]*time.Time{"from": &from, "to": &to} {
+			value := r.FormValue(param)
+			if value == "" {
+				continue
+			}
+			parsed, err := time.Parse(Layout, value)
+			if err != nil {
+				loger.L.Error("time.Parse:", param, value, "err", err)
+				SendErrorResponse(w, ErrInvalidWindow.Error())
+				return
+			}
+			*t = parsed
+		}
+
+		series, err := seriesFromRequest(r, repeat)
+		if err != nil {
+			loger.L.Error("seriesFromRequest:", "err", err)
+			SendRuleError(w, NewRuleError("", repeat, err))
+			return
+		}
+
+		dates, err := Occurrences(dateStr, series, count, from, to)
+		if err != nil {
+			loger.L.Error("Occurrences:", "err", err)
+			SendRuleError(w, NewRuleError("", repeat, err))
+			return
+		}
+
+		WriteJSON(w, OccurrencesResponse{
+			Dates: dates,
+		})
+	})
+}
+
+// RRuleHandle переводит правило повторения между форматом планировщика и RRULE.
+func (h *Api) RRuleHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		repeat := r.FormValue("repeat")
+		if repeat == "" {
+			loger.L.Error(ErrInvalidRepeatParameter.Error())
+			SendErrorResponse(w, ErrInvalidRepeatParameter.Error())
+			return
+		}
+
+		// от даты задачи зависит, совпадают ли правила в обоих форматах
+		date := r.FormValue("date")
+		if date != "" {
+			if _, err := time.Parse(Layout, date); err != nil {
+				SendRuleError(w, NewRuleError("date", "", ErrInvalidDate))
+				return
+			}
+		}
+
+		rrule, err := ToRRule(repeat, date)
+		if err != nil {
+			loger.L.Error("ToRRule:", "repeat", repeat, "err", err)
+			SendRuleError(w, NewRuleError("", repeat, err))
+			return
+		}
+
+		short := repeat
+		if IsRRule(repeat) {
+			// у части RRULE нет аналога в коротком формате, тогда поле остаётся пустым
+			short, err = FromRRule(rrule, date)
+			if err != nil && !errors.Is(err, ErrNotConvertible) {
+				loger.L.Error("FromRRule:", "rrule", rrule, "err", err)
+				SendErrorResponse(w, err.Error())
+				return
+			}
+		}
+
+		WriteJSON(w, RepeatFormats{
+			Repeat: short,
+			RRule:  rrule,
+		})
+	})
+}
+
+// DescribeHandle описывает правило повторения словами. Язык выбирается
+// параметром lang или заголовком Accept-Language.
+func (h *Api) DescribeHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		repeat := r.FormValue("repeat")
+		series, err := seriesFromRequest(r, repeat)
+		if err != nil {
+			loger.L.Error("seriesFromRequest:", "err", err)
+			SendRuleError(w, NewRuleError("", repeat, err))
+			return
+		}
+
+		lang := r.FormValue("lang")
+		if lang == "" {
+			lang = r.Header.Get("Accept-Language")
+		}
+		lang = PreferredLanguage(lang)
+
+		description, err := DescribeSeries(series, lang)
+		if err != nil {
+			loger.L.Error("DescribeSeries:", "repeat", series.Repeat, "err", err)
+			SendRuleError(w, NewRuleError("", repeat, err))
+			return
+		}
+
+		w.Header().Set("Content-Language", lang)
+		WriteJSON(w, DescribeResponse{Description: description})
+	})
+}
+
+// ParsePhraseHandle переводит фразу из параметра text в правило повторения
+// и возвращает его вместе с описанием на языке фразы. Необязательный параметр
+// date — дата задачи, от которой зависят правила «каждые 2 недели» и «каждый месяц».
+func (h *Api) ParsePhraseHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		text := r.FormValue("text")
+		var start time.Time
+		if date := r.FormValue("date"); date != "" {
+			var err error
+			if start, err = time.Parse(Layout, date); err != nil {
+				SendRuleError(w, NewRuleError("date", "", ErrInvalidDate))
+				return
+			}
+		} else {
+			loc, err := h.location(r)
+			if err != nil {
+				SendRuleError(w, err)
+				return
+			}
+			start = wallClock(time.Now(), loc)
+		}
+
+		repeat, err := ParsePhrase(text, start)
+		if err != nil {
+			loger.L.Error("ParsePhrase:", "text", text, "err", err)
+			SendRuleError(w, err)
+			return
+		}
+
+		description, err := Describe(repeat, PhraseLanguage(text))
+		if err != nil {
+			loger.L.Error("Describe:", "repeat", repeat, "err", err)
+			SendErrorResponse(w, err.Error())
+			return
+		}
+
+		WriteJSON(w, ParsedRepeat{
+			Repeat:      repeat,
+			Description: description,
+		})
+	})
+}
+
+// ValidateHandle проверяет правило повторения и условия окончания серии:
+// /api/validate?repeat=m 1,32 -> {"valid":false,"code":"invalid_month_day","token":"32",...}.
+// Ошибка в правиле — ожидаемый ответ, поэтому он отправляется с кодом 200.
+func (h *Api) ValidateHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		repeat := r.FormValue("repeat")
+		err := ValidateRepeat(repeat)
+		var series Series
+		if err == nil {
+			series, err = seriesFromRequest(r, repeat)
+		}
+		if err != nil {
+			ruleErr := NewRuleError("", repeat, err)
+			loger.L.Info("ValidateRepeat:", "repeat", repeat, "err", ruleErr)
+			WriteJSON(w, ValidationResponse{
+				Error:     ruleErr.Error(),
+				RuleError: ruleErr,
+			})
+			return
+		}
+
+		WriteJSON(w, ValidationResponse{
+			Valid:  true,
+			Repeat: series.Repeat,
+		})
+	})
+}
+
 func (h *Api) AddTaskHandle() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var task Task
 		if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
 			loger.L.Error(ErrInvalidJSONFormat.Error())
-			SendErrorResponse(w, ErrInvalidJSONFormat.Error())
+			SendRuleError(w, ErrInvalidJSONFormat)
 			return
 		}
 
 		if task.Title == "" {
 			loger.L.Error(ErrTitleIsEmpty.Error())
-			SendErrorResponse(w, ErrTitleIsEmpty.Error())
+			SendRuleError(w, ErrTitleIsEmpty)
 			return
 		}
 
-		err := checkDate(&task)
+		loc, err := h.location(r)
 		if err != nil {
-			loger.L.Error(ErrTitleIsEmpty.Error())
-			SendErrorResponse(w, ErrTitleIsEmpty.Error())
+			loger.L.Error(err.Error())
+			SendRuleError(w, err)
+			return
+		}
+
+		if err := resolveRepeatText(&task, wallClock(time.Now(), loc)); err != nil {
+			loger.L.Error("resolveRepeatText:", "repeat_text", task.RepeatText, "err", err)
+			SendRuleError(w, err)
+			return
+		}
+
+		// checkDate приводит правило к каноническому виду, а позиция ошибки
+		// должна указывать в правило, которое прислал клиент
+		repeat := task.Repeat
+		err = checkDate(&task, wallClock(time.Now(), loc))
+		if err != nil {
+			loger.L.Error("checkDate:", "err", err)
+			SendRuleError(w, NewRuleError("", repeat, err))
+			return
+		}
+		if task.ListID == "" {
+			task.ListID = DefaultListID
+		}
+		if err := h.checkList(task.ListID); err != nil {
+			loger.L.Error("h.checkList:", "list_id", task.ListID, "err", err)
+			SendRuleError(w, err)
 			return
 		}
 
+		task.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+		task.UpdatedAt, task.Version = task.CreatedAt, FirstVersion
 		id, err := h.Storage.AddTask(task)
 		loger.L.Info("Отпраляем id", "id", id)
 		task.ID = strconv.Itoa(int(id))
@@ -106,6 +377,7 @@ func (h *Api) AddTaskHandle() http.Handler {
 			return
 		}
 
+		w.Header().Set("ETag", ETag(task.Version))
 		if err = json.NewEncoder(w).Encode(task); err != nil {
 			loger.L.Info("Отпраляем id", "id", id)
 			SendIdResponse(w, id)
@@ -116,8 +388,28 @@ func (h *Api) AddTaskHandle() http.Handler {
 
 func (h *Api) GetTasksHandle() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		search := r.URL.Query().Get("search")
-		tasks, err := h.Storage.GetTasks(50, search) // в параметре максимальное количество записей
+		query := r.URL.Query()
+		filter, err := ParseTaskFilter(query.Get("search"), query.Get("tags"), query.Get("match"))
+		if err != nil {
+			loger.L.Error("ParseTaskFilter:", "err", err)
+			SendRuleError(w, err)
+			return
+		}
+		filter.ListID = query.Get("list")
+		if s := query.Get("unblocked"); s != "" {
+			if filter.Unblocked, err = strconv.ParseBool(s); err != nil {
+				loger.L.Error("strconv.ParseBool:", "unblocked", s, "err", err)
+				SendErrorResponse(w, "Неверное значение unblocked")
+				return
+			}
+		}
+		order, err := ParseTaskOrder(query.Get("sort"), query.Get("order"))
+		if err != nil {
+			loger.L.Error("ParseTaskOrder:", "err", err)
+			SendRuleError(w, err)
+			return
+		}
+		tasks, err := h.Storage.GetTasks(50, filter, order) // в параметре максимальное количество записей
 		if err != nil {
 			SendErrorResponse(w, err.Error())
 			return
@@ -149,33 +441,80 @@ func (h *Api) GetTaskHandle() http.Handler {
 		}
 
 		loger.L.Info("task retrieved successfully", "id", id)
+		w.Header().Set("ETag", ETag(task.Version))
 		WriteJSON(w, task)
 	})
 }
 
 func (h *Api) ChangeTaskHandle() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var task Task
-		if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+		var update TaskUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
 			loger.L.Error(ErrInvalidJSONFormat.Error())
-			SendErrorResponse(w, ErrInvalidJSONFormat.Error())
+			SendRuleError(w, ErrInvalidJSONFormat)
 			return
 		}
+		task := update.Task
 
 		if task.Title == "" {
 			loger.L.Error(ErrTitleIsEmpty.Error())
-			SendErrorResponse(w, ErrTitleIsEmpty.Error())
+			SendRuleError(w, ErrTitleIsEmpty)
 			return
 		}
 
-		err := checkDate(&task)
+		if update.Version != nil {
+			if *update.Version < FirstVersion {
+				loger.L.Error(ErrInvalidVersion.Error(), "version", *update.Version)
+				SendRuleError(w, ErrInvalidVersion)
+				return
+			}
+			task.Version = *update.Version
+		}
+		// версия из If-Match важнее версии в теле запроса
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			version, err := ParseIfMatch(ifMatch)
+			if err != nil {
+				loger.L.Error("ParseIfMatch:", "if_match", ifMatch, "err", err)
+				SendRuleError(w, err)
+				return
+			}
+			task.Version = version
+		}
+
+		loc, err := h.location(r)
 		if err != nil {
-			loger.L.Error(ErrTitleIsEmpty.Error())
-			SendErrorResponse(w, ErrTitleIsEmpty.Error())
+			loger.L.Error(err.Error())
+			SendRuleError(w, err)
+			return
+		}
+
+		if err := resolveRepeatText(&task, wallClock(time.Now(), loc)); err != nil {
+			loger.L.Error("resolveRepeatText:", "repeat_text", task.RepeatText, "err", err)
+			SendRuleError(w, err)
+			return
+		}
+
+		// checkDate приводит правило к каноническому виду, а позиция ошибки
+		// должна указывать в правило, которое прислал клиент
+		repeat := task.Repeat
+		err = checkDate(&task, wallClock(time.Now(), loc))
+		if err != nil {
+			loger.L.Error("checkDate:", "err", err)
+			SendRuleError(w, NewRuleError("", repeat, err))
+			return
+		}
+		if err := h.checkList(task.ListID); err != nil {
+			loger.L.Error("h.checkList:", "list_id", task.ListID, "err", err)
+			SendRuleError(w, err)
 			return
 		}
 
 		err = h.Storage.UpdateTask(&task)
+		if errors.Is(err, ErrVersionConflict) {
+			loger.L.Error("failed to update task", "id", task.ID, "error", err)
+			SendConflictError(w, ErrVersionConflict)
+			return
+		}
 		if err != nil {
 			loger.L.Error("failed to update task", "id", task.ID, "error", err)
 			if strings.Contains(err.Error(), "no task found with id") {
@@ -186,7 +525,8 @@ func (h *Api) ChangeTaskHandle() http.Handler {
 			return
 		}
 
-		loger.L.Info("task updated successfully", "id", task.ID)
+		loger.L.Info("task updated successfully", "id", task.ID, "version", task.Version)
+		w.Header().Set("ETag", ETag(task.Version))
 		WriteJSON(w, struct{}{})
 	})
 }
@@ -211,6 +551,48 @@ func (h *Api) DeleteTaskHandle() http.Handler {
 	})
 }
 
+// TrashHandle возвращает задачи из корзины, последние удалённые первыми.
+func (h *Api) TrashHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tasks, err := h.Storage.GetTrash(50)
+		if err != nil {
+			loger.L.Error("h.Storage.GetTrash:", "err", err)
+			SendErrorResponse(w, err.Error())
+			return
+		}
+		WriteJSON(w, TasksResponse{
+			Tasks: tasks,
+		})
+	})
+}
+
+// RestoreTaskHandle возвращает задачу из корзины: /api/task/restore?id=<идентификатор>.
+func (h *Api) RestoreTaskHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			loger.L.Error("no id provided")
+			SendErrorResponse(w, "Не указан идентификатор")
+			return
+		}
+
+		if err := h.Storage.RestoreTask(id); err != nil {
+			loger.L.Error("h.Storage.RestoreTask:", "err", err)
+			SendErrorResponse(w, "В корзине нет задачи с этим ID")
+			return
+		}
+		task, err := h.Storage.GetTask(id)
+		if err != nil {
+			loger.L.Error("h.Storage.GetTask:", "err", err)
+			SendErrorResponse(w, "Ошибка сервера")
+			return
+		}
+
+		loger.L.Info("task restored successfully", "id", id)
+		WriteJSON(w, task)
+	})
+}
+
 func (h *Api) DeleteOrRepeatHandle() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := r.URL.Query().Get("id")
@@ -220,40 +602,687 @@ func (h *Api) DeleteOrRepeatHandle() http.Handler {
 			return
 		}
 
+		loc, err := h.location(r)
+		if err != nil {
+			loger.L.Error(err.Error())
+			SendErrorResponse(w, err.Error())
+			return
+		}
+
 		task, err := h.Storage.GetTask(id)
 		if err != nil {
 			loger.L.Error("cannot do h.Storage.GetTask", "err", err)
 			SendErrorResponse(w, "Нет пользователя с этим ID")
 			return
 		}
+		if h.BlockOpenSubtasks && task.HasOpenSubtasks() {
+			loger.L.Error(ErrOpenSubtasks.Error(), "id", id)
+			SendRuleError(w, ErrOpenSubtasks)
+			return
+		}
+
+		// состояние до выполнения сохраняется, чтобы выполнение можно было отменить
+		completion := Completion{
+			TaskID:      task.ID,
+			Title:       task.Title,
+			Date:        task.Date,
+			CompletedAt: time.Now().UTC().Format(time.RFC3339),
+			Remaining:   task.Remaining,
+			Ease:        task.Ease,
+			Interval:    task.Interval,
+			Repetitions: task.Repetitions,
+			Anchor:      task.Anchor,
+		}
+		for _, s := range task.Subtasks {
+			if s.Done {
+				completion.DoneSubtasks = append(completion.DoneSubtasks, s.ID)
+			}
+		}
 
 		const NoRepeatRule = ""
-		switch task.Repeat {
-		case NoRepeatRule:
-			loger.L.Info("Delete task", "id", task.ID, "repeat", task.Repeat)
-			if err := h.Storage.DeleteTask(id); err != nil {
-				loger.L.Error("no id provided")
-				SendErrorResponse(w, "Нет задачи с этим ID")
+		var newDate, anchor string
+		var steps int
+		reviewed := IsSpaced(task.Repeat)
+		if reviewed {
+			// интервал задачи с правилом s зависит от оценки выполнения
+			grade, err := ParseGrade(r.URL.Query().Get("grade"))
+			if err != nil {
+				loger.L.Error("ParseGrade:", "err", err)
+				SendRuleError(w, err)
 				return
 			}
-			loger.L.Info("task deleted successfully", "id", id)
-		default:
-			loger.L.Info("Update task", "id", task.ID, "repeat", task.Repeat)
-			newDate, err := NextDate(time.Now(), task.Date, task.Repeat)
-			if err != nil {
-				loger.L.Error("h.Storage.DeleteTask:", "err", err)
+			newDate, err = task.Review(wallClock(time.Now(), loc), grade)
+			if errors.Is(err, ErrSeriesEnded) {
+				loger.L.Info("series ended", "id", task.ID, "until", task.Until, "remaining", task.Remaining)
+				task.Repeat = NoRepeatRule
+			}
+			steps = 1
+		} else if task.Repeat != NoRepeatRule {
+			now := wallClock(time.Now(), loc)
+			dstart, series := task.Date, task.Series()
+			if task.FromCompletion {
+				// задача повторяется через заданный срок после выполнения
+				dstart, series.Anchor = now.Format(Layout), ""
+			}
+			newDate, anchor, steps, err = series.next(now, dstart)
+			if errors.Is(err, ErrSeriesEnded) {
+				// серия закончилась — задача удаляется так же, как одноразовая
+				loger.L.Info("series ended", "id", task.ID, "until", task.Until, "remaining", task.Remaining)
+				task.Repeat = NoRepeatRule
+			} else if err != nil {
+				loger.L.Error("task.Series().Next:", "err", err)
 				SendErrorResponse(w, "Невозможно обновить задачу")
 				return
 			}
+		}
 
-			if err := h.Storage.UpdateDate(newDate, id); err != nil {
-				loger.L.Error("h.Storage.UpdateDate:", "err", err)
-				SendErrorResponse(w, "Невозможно обновить задачу")
+		// следующее состояние задачи; nil — задача уходит в архив
+		var next *Task
+		if task.Repeat != NoRepeatRule {
+			loger.L.Info("Update task", "id", task.ID, "repeat", task.Repeat)
+			next = task
+			next.Date, next.Anchor = newDate, anchor
+			if next.Remaining > 0 {
+				next.Remaining -= steps
+			}
+		} else {
+			loger.L.Info("Archive task", "id", task.ID, "repeat", task.Repeat)
+			completion.Archived = true
+		}
+
+		// перенос или архивация и запись о выполнении сохраняются вместе
+		if err := h.Storage.CompleteTask(completion, next); err != nil {
+			loger.L.Error("h.Storage.CompleteTask:", "err", err)
+			SendErrorResponse(w, "Невозможно обновить задачу")
+			return
+		}
+
+		WriteJSON(w, struct{}{})
+	})
+}
+
+// SubtaskDoneHandle отмечает подзадачу выполненной, а с done=false — снова
+// открытой, и возвращает задачу с обновлённым чек-листом.
+func (h *Api) SubtaskDoneHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			loger.L.Error("no id provided")
+			SendErrorResponse(w, "Не указан идентификатор")
+			return
+		}
+		done := true
+		if s := r.URL.Query().Get("done"); s != "" {
+			var err error
+			if done, err = strconv.ParseBool(s); err != nil {
+				loger.L.Error("strconv.ParseBool:", "done", s, "err", err)
+				SendErrorResponse(w, "Неверное значение done")
 				return
 			}
-			loger.L.Info("task updated successfully", "id", id)
 		}
 
+		taskID, err := h.Storage.SetSubtaskDone(id, done)
+		if err != nil {
+			loger.L.Error("h.Storage.SetSubtaskDone:", "err", err)
+			SendErrorResponse(w, "Нет подзадачи с этим ID")
+			return
+		}
+		task, err := h.Storage.GetTask(taskID)
+		if err != nil {
+			loger.L.Error("h.Storage.GetTask:", "err", err)
+			SendErrorResponse(w, "Ошибка сервера")
+			return
+		}
+
+		loger.L.Info("subtask updated successfully", "id", id, "done", done)
+		WriteJSON(w, task)
+	})
+}
+
+// UndoDoneHandle отменяет последнее выполнение задачи: возвращает прежнюю дату
+// и состояние серии или достаёт задачу из архива. Отменить можно в течение UndoWindow.
+func (h *Api) UndoDoneHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			loger.L.Error("no id provided")
+			SendErrorResponse(w, "Не указан идентификатор")
+			return
+		}
+
+		completion, err := h.Storage.GetLastCompletion(id)
+		if err != nil {
+			loger.L.Error("h.Storage.GetLastCompletion:", "err", err)
+			SendErrorResponse(w, "Нет выполнения, которое можно отменить")
+			return
+		}
+		if err := completion.Undoable(time.Now(), h.UndoWindow); err != nil {
+			loger.L.Error("completion.Undoable:", "id", id, "err", err)
+			SendErrorResponse(w, "Время для отмены выполнения истекло")
+			return
+		}
+
+		if err := h.Storage.UndoCompletion(completion); err != nil {
+			loger.L.Error("h.Storage.UndoCompletion:", "err", err)
+			SendErrorResponse(w, "Невозможно отменить выполнение")
+			return
+		}
+		task, err := h.Storage.GetTask(id)
+		if err != nil {
+			loger.L.Error("h.Storage.GetTask:", "err", err)
+			SendErrorResponse(w, "Ошибка сервера")
+			return
+		}
+
+		loger.L.Info("task done undone successfully", "id", id, "date", task.Date)
+		WriteJSON(w, task)
+	})
+}
+
+// AddDependencyHandle делает задачу id зависимой от задачи blocker:
+// /api/task/dependency?id=<идентификатор>&blocker=<идентификатор>.
+// Связь, замыкающая цикл, отклоняется.
+func (h *Api) AddDependencyHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, blocker := r.URL.Query().Get("id"), r.URL.Query().Get("blocker")
+		if id == "" || blocker == "" {
+			loger.L.Error("no id provided")
+			SendErrorResponse(w, "Не указан идентификатор")
+			return
+		}
+		if id == blocker {
+			loger.L.Error(ErrSelfDependency.Error(), "id", id)
+			SendRuleError(w, ErrSelfDependency)
+			return
+		}
+		if _, err := h.Storage.GetTask(id); err != nil {
+			loger.L.Error("h.Storage.GetTask:", "err", err)
+			SendErrorResponse(w, "Задача не найдена")
+			return
+		}
+		if _, err := h.Storage.GetTask(blocker); err != nil {
+			loger.L.Error("h.Storage.GetTask:", "err", err)
+			SendRuleError(w, ErrBlockerNotFound)
+			return
+		}
+
+		err := h.Storage.AddDependency(id, blocker)
+		if errors.Is(err, ErrDependencyCycle) {
+			loger.L.Error("h.Storage.AddDependency:", "err", err)
+			SendRuleError(w, err)
+			return
+		}
+		if err != nil {
+			loger.L.Error("h.Storage.AddDependency:", "err", err)
+			SendErrorResponse(w, "Ошибка сервера")
+			return
+		}
+		h.writeTask(w, id)
+	})
+}
+
+// DeleteDependencyHandle убирает зависимость задачи id от задачи blocker.
+func (h *Api) DeleteDependencyHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, blocker := r.URL.Query().Get("id"), r.URL.Query().Get("blocker")
+		if id == "" || blocker == "" {
+			loger.L.Error("no id provided")
+			SendErrorResponse(w, "Не указан идентификатор")
+			return
+		}
+
+		err := h.Storage.DeleteDependency(id, blocker)
+		if errors.Is(err, ErrDependencyNotFound) {
+			loger.L.Error("h.Storage.DeleteDependency:", "err", err)
+			SendRuleError(w, err)
+			return
+		}
+		if err != nil {
+			loger.L.Error("h.Storage.DeleteDependency:", "err", err)
+			SendErrorResponse(w, "Ошибка сервера")
+			return
+		}
+		h.writeTask(w, id)
+	})
+}
+
+// writeTask отправляет задачу id после её изменения.
+func (h *Api) writeTask(w http.ResponseWriter, id string) {
+	task, err := h.Storage.GetTask(id)
+	if err != nil {
+		loger.L.Error("h.Storage.GetTask:", "err", err)
+		SendErrorResponse(w, "Задача не найдена")
+		return
+	}
+	loger.L.Info("task updated successfully", "id", id)
+	w.Header().Set("ETag", ETag(task.Version))
+	WriteJSON(w, task)
+}
+
+// AddExdateHandle исключает дату из серии повторяющейся задачи:
+// POST /api/task/exdate?id=1&date=20240205. Если исключается текущая дата
+// задачи, задача переносится на следующую дату серии.
+func (h *Api) AddExdateHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		task, date, ok := h.exdateTask(w, r)
+		if !ok {
+			return
+		}
+
+		series, err := Series{Repeat: task.Repeat, Until: task.Until, Exdates: append(task.Exdates, date)}.normalize()
+		if err != nil {
+			loger.L.Error("series.normalize:", "err", err)
+			SendRuleError(w, NewRuleError("", task.Repeat, err))
+			return
+		}
+		task.Exdates = series.Exdates
+
+		if date == task.Date {
+			t, err := time.Parse(Layout, date)
+			if err != nil {
+				SendRuleError(w, ErrInvalidDate)
+				return
+			}
+			// пропущенная дата не расходует Remaining
+			series := Series{Repeat: task.Repeat, Until: task.Until, Exdates: task.Exdates, Anchor: task.Anchor}
+			next, anchor, _, err := series.next(t, task.Date)
+			if err != nil {
+				loger.L.Error("Series.Next:", "err", err)
+				SendRuleError(w, NewRuleError("", task.Repeat, err))
+				return
+			}
+			task.Date, task.Anchor = next, anchor
+		}
+
+		if err := h.Storage.UpdateTask(task); err != nil {
+			loger.L.Error("h.Storage.UpdateTask:", "err", err)
+			SendErrorResponse(w, "Невозможно обновить задачу")
+			return
+		}
+
+		loger.L.Info("exdate added successfully", "id", task.ID, "date", date)
+		WriteJSON(w, task)
+	})
+}
+
+// DeleteExdateHandle возвращает исключённую дату в серию:
+// DELETE /api/task/exdate?id=1&date=20240205. Дата задачи не меняется.
+func (h *Api) DeleteExdateHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		task, date, ok := h.exdateTask(w, r)
+		if !ok {
+			return
+		}
+
+		i := slices.Index(task.Exdates, date)
+		if i < 0 {
+			loger.L.Error(ErrExdateNotFound.Error(), "id", task.ID, "date", date)
+			SendRuleError(w, ErrExdateNotFound)
+			return
+		}
+		task.Exdates = slices.Delete(task.Exdates, i, i+1)
+
+		if err := h.Storage.UpdateTask(task); err != nil {
+			loger.L.Error("h.Storage.UpdateTask:", "err", err)
+			SendErrorResponse(w, "Невозможно обновить задачу")
+			return
+		}
+
+		loger.L.Info("exdate deleted successfully", "id", task.ID, "date", date)
+		WriteJSON(w, task)
+	})
+}
+
+// exdateTask читает параметры id и date запроса и загружает задачу.
+// Если запрос неверен, ответ с ошибкой уже отправлен и ok равно false.
+func (h *Api) exdateTask(w http.ResponseWriter, r *http.Request) (task *Task, date string, ok bool) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		loger.L.Error("no id provided")
+		SendErrorResponse(w, "Не указан идентификатор")
+		return nil, "", false
+	}
+	date = r.URL.Query().Get("date")
+	if _, err := time.Parse(Layout, date); err != nil {
+		loger.L.Error(ErrInvalidExdate.Error(), "date", date)
+		SendRuleError(w, ErrInvalidExdate)
+		return nil, "", false
+	}
+
+	task, err := h.Storage.GetTask(id)
+	if err != nil {
+		loger.L.Error("cannot do h.Storage.GetTask", "err", err)
+		SendErrorResponse(w, "Нет задачи с этим ID")
+		return nil, "", false
+	}
+	if task.Repeat == "" {
+		loger.L.Error(ErrExdatesWithoutRepeat.Error(), "id", id)
+		SendRuleError(w, ErrExdatesWithoutRepeat)
+		return nil, "", false
+	}
+	return task, date, true
+}
+
+// HistoryHandle возвращает выполненные задачи от новых к старым:
+// /api/history?from=20240101&to=20240131&id=<идентификатор задачи>.
+// Даты from и to ограничивают день выполнения включительно.
+func (h *Api) HistoryHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loc, err := h.location(r)
+		if err != nil {
+			loger.L.Error("h.location:", "err", err)
+			SendRuleError(w, err)
+			return
+		}
+
+		// from и to — дни в часовом поясе пользователя, а completed_at хранится
+		// в UTC, поэтому дни превращаются в границы [начало from, конец to) в UTC
+		var bounds [2]string
+		for i, name := range []string{"from", "to"} {
+			value := r.URL.Query().Get(name)
+			if value == "" {
+				continue
+			}
+			date, err := time.ParseInLocation(Layout, value, loc)
+			if err != nil {
+				loger.L.Error(ErrInvalidDate.Error(), name, value)
+				SendRuleError(w, NewRuleError(name, "", ErrInvalidDate))
+				return
+			}
+			if name == "to" {
+				date = date.AddDate(0, 0, 1)
+			}
+			bounds[i] = date.UTC().Format(time.RFC3339)
+		}
+
+		completions, err := h.Storage.GetHistory(MaxOccurrences, bounds[0], bounds[1], r.URL.Query().Get("id"))
+		if err != nil {
+			loger.L.Error("h.Storage.GetHistory:", "err", err)
+			SendErrorResponse(w, "Ошибка сервера")
+			return
+		}
+		for i := range completions {
+			if completedAt, err := time.Parse(time.RFC3339, completions[i].CompletedAt); err == nil {
+				completions[i].CompletedAt = completedAt.In(loc).Format(time.RFC3339)
+			}
+		}
+		WriteJSON(w, HistoryResponse{
+			Completions: completions,
+		})
+	})
+}
+
+func (h *Api) GetListsHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lists, err := h.Storage.GetLists()
+		if err != nil {
+			loger.L.Error("h.Storage.GetLists:", "err", err)
+			SendErrorResponse(w, "Ошибка сервера")
+			return
+		}
+		WriteJSON(w, ListsResponse{Lists: lists})
+	})
+}
+
+// AddListHandle создаёт список {"name": "Работа"} и возвращает его id.
+func (h *Api) AddListHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var list List
+		if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+			loger.L.Error(ErrInvalidJSONFormat.Error())
+			SendRuleError(w, ErrInvalidJSONFormat)
+			return
+		}
+		name, err := NormalizeListName(list.Name)
+		if err != nil {
+			loger.L.Error("NormalizeListName:", "err", err, "name", list.Name)
+			SendRuleError(w, err)
+			return
+		}
+
+		id, err := h.Storage.AddList(name)
+		if errors.Is(err, ErrListExists) {
+			SendRuleError(w, err)
+			return
+		}
+		if err != nil {
+			loger.L.Error("h.Storage.AddList:", "err", err)
+			SendErrorResponse(w, "Ошибка сервера")
+			return
+		}
+
+		loger.L.Info("list added successfully", "id", id, "name", name)
+		SendIdResponse(w, id)
+	})
+}
+
+// RenameListHandle переименовывает список {"id": "2", "name": "Дом"}.
+func (h *Api) RenameListHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var list List
+		if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+			loger.L.Error(ErrInvalidJSONFormat.Error())
+			SendRuleError(w, ErrInvalidJSONFormat)
+			return
+		}
+		if list.ID == "" {
+			loger.L.Error("no id provided")
+			SendErrorResponse(w, "Не указан идентификатор")
+			return
+		}
+		name, err := NormalizeListName(list.Name)
+		if err != nil {
+			loger.L.Error("NormalizeListName:", "err", err, "name", list.Name)
+			SendRuleError(w, err)
+			return
+		}
+
+		err = h.Storage.RenameList(list.ID, name)
+		if errors.Is(err, ErrListExists) {
+			SendRuleError(w, err)
+			return
+		}
+		if err != nil {
+			loger.L.Error("h.Storage.RenameList:", "err", err)
+			SendErrorResponse(w, "Нет списка с этим ID")
+			return
+		}
+
+		loger.L.Info("list renamed successfully", "id", list.ID, "name", name)
+		WriteJSON(w, struct{}{})
+	})
+}
+
+// DeleteListHandle удаляет список; его задачи переносятся во «Входящие».
+func (h *Api) DeleteListHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			loger.L.Error("no id provided")
+			SendErrorResponse(w, "Не указан идентификатор")
+			return
+		}
+		if id == DefaultListID {
+			loger.L.Error(ErrDefaultList.Error())
+			SendRuleError(w, ErrDefaultList)
+			return
+		}
+
+		if err := h.Storage.DeleteList(id); err != nil {
+			loger.L.Error("h.Storage.DeleteList:", "err", err)
+			SendErrorResponse(w, "Нет списка с этим ID")
+			return
+		}
+
+		loger.L.Info("list deleted successfully", "id", id)
+		WriteJSON(w, struct{}{})
+	})
+}
+
+func (h *Api) GetTagsHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tags, err := h.Storage.GetTags()
+		if err != nil {
+			loger.L.Error("h.Storage.GetTags:", "err", err)
+			SendErrorResponse(w, "Ошибка сервера")
+			return
+		}
+		WriteJSON(w, TagsResponse{Tags: tags})
+	})
+}
+
+// AddTagHandle создаёт метку {"name": "работа"} и возвращает её id.
+func (h *Api) AddTagHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var tag Tag
+		if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+			loger.L.Error(ErrInvalidJSONFormat.Error())
+			SendRuleError(w, ErrInvalidJSONFormat)
+			return
+		}
+		name, err := NormalizeTag(tag.Name)
+		if err != nil {
+			loger.L.Error("NormalizeTag:", "err", err, "name", tag.Name)
+			SendRuleError(w, err)
+			return
+		}
+
+		id, err := h.Storage.AddTag(name)
+		if errors.Is(err, ErrTagExists) {
+			SendRuleError(w, err)
+			return
+		}
+		if err != nil {
+			loger.L.Error("h.Storage.AddTag:", "err", err)
+			SendErrorResponse(w, "Ошибка сервера")
+			return
+		}
+
+		loger.L.Info("tag added successfully", "id", id, "name", name)
+		SendIdResponse(w, id)
+	})
+}
+
+// RenameTagHandle переименовывает метку {"id": "1", "name": "дом"} у всех задач.
+func (h *Api) RenameTagHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var tag Tag
+		if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+			loger.L.Error(ErrInvalidJSONFormat.Error())
+			SendRuleError(w, ErrInvalidJSONFormat)
+			return
+		}
+		if tag.ID == "" {
+			loger.L.Error("no id provided")
+			SendErrorResponse(w, "Не указан идентификатор")
+			return
+		}
+		name, err := NormalizeTag(tag.Name)
+		if err != nil {
+			loger.L.Error("NormalizeTag:", "err", err, "name", tag.Name)
+			SendRuleError(w, err)
+			return
+		}
+
+		err = h.Storage.RenameTag(tag.ID, name)
+		if errors.Is(err, ErrTagExists) {
+			SendRuleError(w, err)
+			return
+		}
+		if err != nil {
+			loger.L.Error("h.Storage.RenameTag:", "err", err)
+			SendErrorResponse(w, "Нет метки с этим ID")
+			return
+		}
+
+		loger.L.Info("tag renamed successfully", "id", tag.ID, "name", name)
+		WriteJSON(w, struct{}{})
+	})
+}
+
+// DeleteTagHandle удаляет метку и снимает её со всех задач.
+func (h *Api) DeleteTagHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			loger.L.Error("no id provided")
+			SendErrorResponse(w, "Не указан идентификатор")
+			return
+		}
+
+		if err := h.Storage.DeleteTag(id); err != nil {
+			loger.L.Error("h.Storage.DeleteTag:", "err", err)
+			SendErrorResponse(w, "Нет метки с этим ID")
+			return
+		}
+
+		loger.L.Info("tag deleted successfully", "id", id)
+		WriteJSON(w, struct{}{})
+	})
+}
+
+func (h *Api) GetHolidaysHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		days, err := h.Storage.GetHolidays()
+		if err != nil {
+			loger.L.Error("h.Storage.GetHolidays:", "err", err)
+			SendErrorResponse(w, err.Error())
+			return
+		}
+		WriteJSON(w, HolidaysResponse{
+			Holidays: days,
+		})
+	})
+}
+
+func (h *Api) AddHolidayHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var day calendar.Day
+		if err := json.NewDecoder(r.Body).Decode(&day); err != nil {
+			loger.L.Error(ErrInvalidJSONFormat.Error())
+			SendErrorResponse(w, ErrInvalidJSONFormat.Error())
+			return
+		}
+
+		if _, err := time.Parse(Layout, day.Date); err != nil {
+			loger.L.Error(ErrInvalidDate.Error(), "date", day.Date)
+			SendErrorResponse(w, ErrInvalidDate.Error())
+			return
+		}
+
+		if err := h.Storage.AddHoliday(day); err != nil {
+			loger.L.Error("h.Storage.AddHoliday:", "err", err)
+			SendErrorResponse(w, "Ошибка сервера")
+			return
+		}
+		if err := calendar.C.Set(day); err != nil {
+			loger.L.Error("calendar.C.Set:", "err", err)
+			SendErrorResponse(w, err.Error())
+			return
+		}
+
+		loger.L.Info("holiday added successfully", "date", day.Date)
+		WriteJSON(w, struct{}{})
+	})
+}
+
+func (h *Api) DeleteHolidayHandle() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		date := r.URL.Query().Get("date")
+		if date == "" {
+			loger.L.Error("no date provided")
+			SendErrorResponse(w, "Не указана дата")
+			return
+		}
+
+		if err := h.Storage.DeleteHoliday(date); err != nil {
+			loger.L.Error("h.Storage.DeleteHoliday:", "err", err)
+			SendErrorResponse(w, "Нет праздника с этой датой")
+			return
+		}
+		calendar.C.Delete(date)
+
+		loger.L.Info("holiday deleted successfully", "date", date)
 		WriteJSON(w, struct{}{})
 	})
 }
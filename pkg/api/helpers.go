@@ -5,8 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"slices"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,172 +14,61 @@ import (
 
 const Layout = "20060102"
 
+// TimeLayout — формат времени начала задачи.
+const TimeLayout = "15:04"
+
+// maxDuration — наибольшая длительность задачи в минутах.
+const maxDuration = 24 * 60
+
+// TimezoneHeader — заголовок, которым клиент переопределяет часовой пояс.
+// Вместо него можно передать параметр запроса tz.
+const TimezoneHeader = "X-Timezone"
+
 const (
 	day   = "d"
 	year  = "y"
 	week  = "w"
 	month = "m"
+	// nthWeekday — n-й день недели месяца: "n 2:2" — второй вторник,
+	// "n -1:5 1,6" — последняя пятница января и июня.
+	nthWeekday = "n"
 )
 
 var (
-	ErrInvalidRepeatParameter error = errors.New("arg repeat is empty")
-	ErrUnknownFormat          error = errors.New("unknown format in repeat")
-	ErrManyDays               error = errors.New("days are more than 400")
-	ErrManyWeeks              error = errors.New("weeks are more than 7")
-	ErrManyMonths             error = errors.New("months are more than 12")
-	ErrInvalidFormatInDay     error = errors.New("format of day is incorrect")
-	ErrInvalidFormatInMonth   error = errors.New("format of month is incorrect")
+	ErrInvalidRepeatParameter     error = errors.New("arg repeat is empty")
+	ErrUnknownFormat              error = errors.New("unknown format in repeat")
+	ErrManyDays                   error = errors.New("days are more than 400")
+	ErrManyWeeks                  error = errors.New("weeks are more than 7")
+	ErrManyMonths                 error = errors.New("months are more than 12")
+	ErrInvalidFormatInDay         error = errors.New("format of day is incorrect")
+	ErrInvalidFormatInMonth       error = errors.New("format of month is incorrect")
+	ErrInvalidFormatInNth         error = errors.New("format of nth weekday is incorrect")
+	ErrInvalidFormatInBusinessDay error = errors.New("format of business day is incorrect")
+	ErrInvalidWeekInterval        error = errors.New("week interval must be from 1 to 52")
+	ErrInvalidYearInterval        error = errors.New("year interval must be from 1 to 100")
+	ErrInvalidTime                error = errors.New("time is in invalid format")
+	ErrInvalidDuration            error = errors.New("duration must be from 0 to 1440 minutes")
+	ErrInvalidTimezone            error = errors.New("unknown timezone")
 )
 
+// NextDate возвращает следующую дату задачи по правилу repeat. Если dstart
+// содержит время ("20240126 14:30"), оно сохраняется и в возвращаемой дате.
+// Чтобы не разбирать одно правило много раз, используйте ParseRepeat.
 func NextDate(now time.Time, dstart string, repeat string) (string, error) {
 	if repeat == "" {
 		return "", ErrInvalidRepeatParameter
 	}
-	repeatSlice := strings.Split(repeat, " ")
 
-	timeDstart, err := time.Parse(Layout, dstart)
-	if err != nil {
+	date, _, _ := strings.Cut(dstart, " ")
+	if _, err := time.Parse(Layout, date); err != nil {
 		return "", fmt.Errorf("time.Parse: cannot parse dstart: %w", err)
 	}
 
-	switch repeatSlice[0] {
-	case day:
-		if len(repeatSlice) < 2 {
-			return "", ErrInvalidFormatInDay
-		}
-		days, err := strconv.Atoi(repeatSlice[1])
-		if err != nil {
-			return "", fmt.Errorf("strconv.Atoi: cannot convet string to int: %w", err)
-		}
-
-		if days > 400 {
-			return "", ErrManyDays
-		}
-
-		for {
-			timeDstart = timeDstart.AddDate(0, 0, days)
-			if timeDstart.After(now) {
-				break
-			}
-		}
-	case year:
-		for {
-			timeDstart = time.Date(timeDstart.Year()+1, timeDstart.Month(), timeDstart.Day(), 0, 0, 0, 0, timeDstart.Location())
-			if timeDstart.Equal(now) || timeDstart.After(now) {
-				break
-			}
-		}
-	case week:
-		if len(repeatSlice) < 2 {
-			return "", ErrInvalidRepeatParameter
-		}
-		daysOfWeekStr := strings.Split(repeatSlice[1], ",")
-		var daysInt []int
-		for _, dayStr := range daysOfWeekStr {
-			dayInt, err := strconv.Atoi(dayStr)
-			if err != nil {
-				return "", fmt.Errorf("strconv.Atoi: cannot convet string to int: %w", err)
-			}
-			if dayInt > 7 {
-				return "", ErrManyWeeks
-			}
-			daysInt = append(daysInt, dayInt-1)
-		}
-		i := int(timeDstart.Weekday())
-		for {
-			timeDstart = timeDstart.AddDate(0, 0, 1)
-			if slices.Contains(daysInt, i) && timeDstart.After(now) {
-				break
-			}
-			i = (i + 1) % 7
-		}
-	case month:
-		daysOfMonthsStr := strings.Split(repeatSlice[1], ",")
-		var monthsOfYearStr []string
-		if len(repeatSlice) > 2 {
-			monthsOfYearStr = strings.Split(repeatSlice[2], ",")
-		}
-		var daysOfMonthsInts []int
-		var monthsOfYearInts []int
-		var includeLastDay bool
-		var includePreLastDay bool
-		for _, daysOfMonths := range daysOfMonthsStr {
-			dayOfMonthInt, err := strconv.Atoi(daysOfMonths)
-			if err != nil {
-				return "", fmt.Errorf("strconv.Atoi: cannot convet string to int: %w", err)
-			}
-			switch {
-			case dayOfMonthInt == -1:
-				includeLastDay = true
-			case dayOfMonthInt == -2:
-				includePreLastDay = true
-			case dayOfMonthInt < 1 || dayOfMonthInt > 31:
-				return "", ErrInvalidFormatInMonth
-			default:
-				daysOfMonthsInts = append(daysOfMonthsInts, dayOfMonthInt)
-			}
-		}
-		if len(monthsOfYearStr) == 0 {
-			monthsOfYearInts = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
-		} else {
-			for _, monthOfYearStr := range monthsOfYearStr {
-				monthOfYearInt, err := strconv.Atoi(monthOfYearStr)
-				if err != nil {
-					return "", fmt.Errorf("strconv.Atoi: cannot convet string to int: %w", err)
-				}
-				if monthOfYearInt > 12 {
-					return "", ErrManyMonths
-				}
-				monthsOfYearInts = append(monthsOfYearInts, monthOfYearInt)
-			}
-			sort.Ints(monthsOfYearInts)
-		}
-
-		current := timeDstart
-		for yearOffset := 0; yearOffset < 10; yearOffset++ {
-			year := current.Year() + yearOffset
-			startMonth := 1
-			if yearOffset == 0 {
-				startMonth = int(current.Month())
-			}
-
-			for _, monthInt := range monthsOfYearInts {
-				if yearOffset == 0 && monthInt < startMonth {
-					continue
-				}
-
-				month := time.Month(monthInt)
-				daysToCheck := make([]int, len(daysOfMonthsInts))
-				copy(daysToCheck, daysOfMonthsInts)
-
-				if includeLastDay {
-					lastDay := LastDayOfMonth(time.Date(year, month, 1, 0, 0, 0, 0, current.Location()))
-					daysToCheck = append(daysToCheck, lastDay.Day())
-				}
-				if includePreLastDay {
-					preLastDay := PreLastDayOfMonth(time.Date(year, month, 1, 0, 0, 0, 0, current.Location()))
-					daysToCheck = append(daysToCheck, preLastDay.Day())
-				}
-
-				sort.Ints(daysToCheck)
-
-				for _, day := range daysToCheck {
-					testDate := time.Date(year, month, day, 0, 0, 0, 0, current.Location())
-					if testDate.Month() != month {
-						continue
-					}
-					if testDate.After(now) {
-						return testDate.Format("20060102"), nil
-					}
-				}
-			}
-		}
-
-	default:
-		return "", ErrUnknownFormat
+	rule, err := ParseRepeat(repeat)
+	if err != nil {
+		return "", err
 	}
-
-	return timeDstart.Format(Layout), nil
+	return rule.NextDate(now, dstart)
 }
 
 func LastDayOfMonth(t time.Time) time.Time {
@@ -204,6 +91,30 @@ func SendErrorResponse(w http.ResponseWriter, errorMsg string) {
 	}
 }
 
+// SendRuleError отправляет ошибку вместе с кодом, полем и ошибочной частью правила.
+func SendRuleError(w http.ResponseWriter, err error) {
+	sendRuleError(w, http.StatusBadRequest, err)
+}
+
+// SendConflictError отправляет ошибку с кодом 409 Conflict.
+func SendConflictError(w http.ResponseWriter, err error) {
+	sendRuleError(w, http.StatusConflict, err)
+}
+
+func sendRuleError(w http.ResponseWriter, status int, err error) {
+	ruleErr := NewRuleError("", "", err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	response := Response{
+		Error:     ruleErr.Error(),
+		RuleError: ruleErr,
+	}
+	loger.L.Info("Response sent", "response", response)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode JSON", http.StatusInternalServerError)
+	}
+}
+
 func SendIdResponse(w http.ResponseWriter, id int64) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -225,8 +136,43 @@ func WriteJSON(w http.ResponseWriter, data any) {
 	}
 }
 
-func checkDate(task *Task) error {
-	now := time.Now()
+func checkDate(task *Task, now time.Time) error {
+	if task.Time != "" {
+		if _, err := time.Parse(TimeLayout, task.Time); err != nil {
+			return ErrInvalidTime
+		}
+	}
+	if task.Duration < 0 || task.Duration > maxDuration {
+		return ErrInvalidDuration
+	}
+	if task.Priority < 0 || task.Priority > maxPriority {
+		return ErrInvalidPriority
+	}
+	tags, err := normalizeTags(task.Tags)
+	if err != nil {
+		return err
+	}
+	task.Tags = tags
+	if err := checkSubtasks(task); err != nil {
+		return err
+	}
+
+	series, err := task.Series().normalize()
+	if err != nil {
+		return fmt.Errorf("normalize: invalid series: %w", err)
+	}
+	task.Repeat, task.Until, task.Remaining = series.Repeat, series.Until, series.Remaining
+	task.Exdates = series.Exdates
+	if err := checkSpaced(task); err != nil {
+		return err
+	}
+
+	if task.Time == "" && IsCron(task.Repeat) {
+		// время начала берётся из полей минуты и часа cron-выражения
+		if rule, err := ParseRepeat(task.Repeat); err == nil {
+			task.Time, _ = rule.TimeOfDay()
+		}
+	}
 
 	if task.Date == "" {
 		task.Date = now.Format(Layout)
@@ -238,19 +184,32 @@ func checkDate(task *Task) error {
 		return ErrInvalidDate
 	}
 
-	var next string
+	// исключённую дату задача тоже пропускает, даже если она ещё не наступила
+	excluded := task.Repeat != "" && series.excluded(t)
+
+	var next, anchor string
+	var nextErr error
 	if task.Repeat != "" {
-		next, err = NextDate(now, task.Date, task.Repeat)
-		if err != nil {
-			return fmt.Errorf("NextDate: cannot get NextDate: %w", err)
+		from := now
+		if t.After(from) {
+			from = t
+		}
+		// Remaining не ограничивает перенос: задача ещё не выполнялась
+		series := Series{Repeat: task.Repeat, Until: task.Until, Exdates: task.Exdates, Anchor: task.Anchor}
+		next, anchor, _, nextErr = series.next(from, task.Date)
+		if nextErr != nil && !errors.Is(nextErr, ErrSeriesEnded) {
+			return fmt.Errorf("Series.Next: cannot get next date: %w", nextErr)
 		}
 	}
 
-	if afterNow(now, t) {
+	if afterNow(now, t) || excluded {
 		if task.Repeat == "" {
 			task.Date = now.Format(Layout)
 		} else {
-			task.Date = next
+			if nextErr != nil {
+				return nextErr
+			}
+			task.Date, task.Anchor = next, anchor
 		}
 	}
 
@@ -270,3 +229,48 @@ func IsDate(search string) (string, bool) {
 	}
 	return date.Format("20060102"), true
 }
+
+// seriesFromRequest собирает серию из правила repeat и необязательных
+// параметров until, remaining и exdates (даты через запятую) запроса.
+func seriesFromRequest(r *http.Request, repeat string) (Series, error) {
+	series := Series{
+		Repeat: repeat,
+		Until:  r.FormValue("until"),
+	}
+	if exdates := r.FormValue("exdates"); exdates != "" {
+		series.Exdates = strings.Split(exdates, ",")
+	}
+	if remaining := r.FormValue("remaining"); remaining != "" {
+		var err error
+		series.Remaining, err = strconv.Atoi(remaining)
+		if err != nil {
+			return series, ErrInvalidRemaining
+		}
+	}
+	return series.normalize()
+}
+
+// location возвращает часовой пояс запроса: параметр tz, заголовок
+// X-Timezone или часовой пояс по умолчанию.
+func (h *Api) location(r *http.Request) (*time.Location, error) {
+	name := r.URL.Query().Get("tz")
+	if name == "" {
+		name = r.Header.Get(TimezoneHeader)
+	}
+	if name == "" {
+		return h.Location, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTimezone, name)
+	}
+	return loc, nil
+}
+
+// wallClock переводит t в часовой пояс loc и возвращает те же показания часов
+// в UTC. Даты задач разбираются в UTC, поэтому сравнение с ними идёт по
+// календарю пользователя, а арифметика дат не задевает переходы на летнее время.
+func wallClock(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultListID — список «Входящие»: в нём оказываются задачи без списка
+// и задачи удалённых списков. Его нельзя удалить.
+const DefaultListID = "1"
+
+// maxListNameLength — наибольшая длина названия списка в символах.
+const maxListNameLength = 128
+
+var (
+	ErrInvalidListName error = errors.New("list name must be from 1 to 128 characters")
+	ErrListExists      error = errors.New("list already exists")
+	ErrListNotFound    error = errors.New("list not found")
+	ErrDefaultList     error = errors.New("default list cannot be deleted")
+)
+
+// NormalizeListName убирает пробелы по краям названия списка и проверяет его длину.
+func NormalizeListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxListNameLength {
+		return "", ErrInvalidListName
+	}
+	return name, nil
+}
+
+// checkList проверяет, что список задачи существует. Пустой список не проверяется.
+func (h *Api) checkList(id string) error {
+	if id == "" {
+		return nil
+	}
+	if _, err := h.Storage.GetList(id); err != nil {
+		return ErrListNotFound
+	}
+	return nil
+}
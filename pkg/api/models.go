@@ -1,18 +1,147 @@
 package api
 
+import "github.com/NarthurN/TODO-API-web/pkg/calendar"
+
 type Task struct {
 	ID      string `json:"id"`
 	Title   string `json:"title"`
 	Date    string `json:"date"`
 	Comment string `json:"comment"`
 	Repeat  string `json:"repeat"`
+	// Until — последняя дата серии повторений, Remaining — сколько повторений
+	// осталось, включая текущее. Нулевые значения означают отсутствие ограничения.
+	Until     string `json:"until,omitempty"`
+	Remaining int    `json:"remaining,omitempty"`
+	// Time — время начала в формате HH:MM, Duration — длительность в минутах.
+	Time     string `json:"time,omitempty"`
+	Duration int    `json:"duration,omitempty"`
+	// ListID — список, в котором лежит задача; при создании без списка задача
+	// попадает во «Входящие», при изменении без списка остаётся в своём.
+	ListID string `json:"list_id,omitempty"`
+	// Priority — приоритет от 0 (не задан) до 3 (высокий).
+	Priority int `json:"priority,omitempty"`
+	// FromCompletion — следующая дата отсчитывается от дня выполнения, а не от даты задачи.
+	FromCompletion bool `json:"from_completion,omitempty"`
+	// Ease, Interval и Repetitions — состояние интервального повторения (правило s):
+	// коэффициент лёгкости, последний интервал в днях и число успешных повторений подряд.
+	Ease        float64 `json:"ease,omitempty"`
+	Interval    int     `json:"interval,omitempty"`
+	Repetitions int     `json:"repetitions,omitempty"`
+	// DeletedAt — момент переноса задачи в корзину (RFC 3339); заполнен только в корзине.
+	DeletedAt string `json:"deleted_at,omitempty"`
+	// Tags — метки задачи в нижнем регистре, без "#".
+	Tags []string `json:"tags,omitempty"`
+	// Subtasks — упорядоченный чек-лист задачи. При изменении задачи без поля
+	// subtasks чек-лист остаётся прежним, пустой массив его очищает.
+	Subtasks []Subtask `json:"subtasks,omitempty"`
+	// Progress — доля выполненных подзадач в процентах; не хранится и
+	// отсутствует, если подзадач нет.
+	Progress *int `json:"progress,omitempty"`
+	// BlockedBy — открытые задачи, которые нужно выполнить раньше этой,
+	// Blocks — открытые задачи, которые ждут эту. Задаются через /api/task/dependency.
+	BlockedBy []string `json:"blocked_by,omitempty"`
+	Blocks    []string `json:"blocks,omitempty"`
+	// Exdates — даты YYYYMMDD, которые повторяющаяся задача пропускает.
+	Exdates []string `json:"exdates,omitempty"`
+	// Anchor — дата до переноса на рабочий день (см. Series.Anchor); клиенту не отдаётся.
+	Anchor string `json:"-"`
+	// CreatedAt и UpdatedAt — моменты создания и последнего изменения (RFC 3339, UTC).
+	CreatedAt string `json:"created_at,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+	// Version растёт при каждом изменении задачи. Клиенту она отдаётся в
+	// заголовке ETag, а обратно приходит в If-Match или числом в поле version
+	// тела PUT /api/task (см. TaskUpdate). 0 означает запись без проверки версии.
+	Version int `json:"-"`
+	// RepeatText — правило повторения словами ("каждый вторник"), заменяет Repeat
+	// при создании и изменении задачи и не хранится.
+	RepeatText string `json:"repeat_text,omitempty"`
 }
 
 type Response struct {
 	ID    int64  `json:"id,omitempty"`
 	Error string `json:"error,omitempty"`
+	// RuleError дополняет ошибку кодом, полем и ошибочной частью правила.
+	*RuleError
+}
+
+type ValidationResponse struct {
+	Valid bool `json:"valid"`
+	// Repeat — правило в каноническом виде, если оно верное.
+	Repeat string `json:"repeat,omitempty"`
+	Error  string `json:"error,omitempty"`
+	*RuleError
 }
 
 type TasksResponse struct {
 	Tasks []Task `json:"tasks"`
 }
+
+type OccurrencesResponse struct {
+	Dates []string `json:"dates"`
+}
+
+type RepeatFormats struct {
+	Repeat string `json:"repeat,omitempty"`
+	RRule  string `json:"rrule"`
+}
+
+type ParsedRepeat struct {
+	Repeat      string `json:"repeat"`
+	Description string `json:"description"`
+}
+
+type DescribeResponse struct {
+	Description string `json:"description"`
+}
+
+// Completion — запись о выполнении задачи: её заголовок и дата на момент выполнения.
+type Completion struct {
+	ID     string `json:"id"`
+	TaskID string `json:"task_id"`
+	Title  string `json:"title"`
+	// Date — дата, на которую задача была запланирована.
+	Date string `json:"date"`
+	// CompletedAt — момент выполнения в формате RFC 3339: хранится в UTC, а в истории
+	// отдаётся в часовом поясе пользователя.
+	CompletedAt string `json:"completed_at"`
+	// Remaining, Ease, Interval и Repetitions — состояние серии до выполнения,
+	// Archived — выполнение отправило задачу в архив, DoneSubtasks — id
+	// выполненных подзадач, Anchor — Task.Anchor. По ним выполнение отменяется.
+	Remaining    int      `json:"-"`
+	Ease         float64  `json:"-"`
+	Interval     int      `json:"-"`
+	Repetitions  int      `json:"-"`
+	Archived     bool     `json:"-"`
+	DoneSubtasks []string `json:"-"`
+	Anchor       string   `json:"-"`
+}
+
+type HistoryResponse struct {
+	Completions []Completion `json:"completions"`
+}
+
+// Tag — метка; Tasks — число задач с ней.
+type Tag struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Tasks int    `json:"tasks"`
+}
+
+type TagsResponse struct {
+	Tags []Tag `json:"tags"`
+}
+
+// List — список задач; Tasks — число задач в нём.
+type List struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Tasks int    `json:"tasks"`
+}
+
+type ListsResponse struct {
+	Lists []List `json:"lists"`
+}
+
+type HolidaysResponse struct {
+	Holidays []calendar.Day `json:"holidays"`
+}
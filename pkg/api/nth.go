@@ -0,0 +1,77 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseNthWeekday разбирает правило вида "n 2:2,-1:5 [1,6]": список пар
+// <номер недели>:<день недели> и необязательный список месяцев.
+// Номер недели — от 1 до 4 или -1 для последней, день недели — от 1 (пн) до 7 (вс).
+// Правило вычисляется тем же движком, что и RRULE с FREQ=MONTHLY и BYDAY.
+func parseNthWeekday(repeatSlice []string) (rrule, error) {
+	if len(repeatSlice) < 2 || len(repeatSlice) > 3 {
+		return rrule{}, ErrInvalidFormatInNth
+	}
+
+	r := rrule{freq: freqMonthly, interval: 1}
+	for _, item := range strings.Split(repeatSlice[1], ",") {
+		ordStr, dayStr, ok := strings.Cut(item, ":")
+		if !ok {
+			return rrule{}, ErrInvalidFormatInNth
+		}
+		ord, err := strconv.Atoi(ordStr)
+		if err != nil || ord == 0 || ord < -1 || ord > 4 {
+			return rrule{}, ErrInvalidFormatInNth
+		}
+		dayInt, err := strconv.Atoi(dayStr)
+		if err != nil || dayInt < 1 {
+			return rrule{}, ErrInvalidFormatInNth
+		}
+		if dayInt > 7 {
+			return rrule{}, ErrManyWeeks
+		}
+		r.byDay = append(r.byDay, weekdayNum{n: ord, weekday: time.Weekday(dayInt % 7)})
+	}
+
+	if len(repeatSlice) == 3 {
+		for _, monthStr := range strings.Split(repeatSlice[2], ",") {
+			monthInt, err := strconv.Atoi(monthStr)
+			if err != nil || monthInt < 1 {
+				return rrule{}, ErrInvalidFormatInNth
+			}
+			if monthInt > 12 {
+				return rrule{}, ErrManyMonths
+			}
+			r.byMonth = append(r.byMonth, monthInt)
+		}
+	}
+
+	return r, nil
+}
+
+// isNthWeekday сообщает, можно ли записать RRULE правилом "n".
+func (r rrule) isNthWeekday() bool {
+	if r.freq != freqMonthly || r.interval != 1 || len(r.byDay) == 0 || len(r.byMonthDay) > 0 {
+		return false
+	}
+	for _, d := range r.byDay {
+		if d.n == 0 || d.n < -1 || d.n > 4 {
+			return false
+		}
+	}
+	return true
+}
+
+func (r rrule) nthWeekdayString() string {
+	items := make([]string, 0, len(r.byDay))
+	for _, d := range r.byDay {
+		items = append(items, strconv.Itoa(d.n)+":"+strconv.Itoa((int(d.weekday)+6)%7+1))
+	}
+	short := nthWeekday + " " + strings.Join(items, ",")
+	if len(r.byMonth) > 0 {
+		short += " " + joinInts(r.byMonth)
+	}
+	return short
+}
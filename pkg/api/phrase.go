@@ -0,0 +1,455 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrInvalidPhrase       error = errors.New("cannot parse repeat phrase")
+	ErrRepeatAndRepeatText error = errors.New("repeat and repeat_text cannot be set together")
+)
+
+// Единицы периода во фразе.
+const (
+	unitDay   = "day"
+	unitWeek  = "week"
+	unitMonth = "month"
+	unitYear  = "year"
+)
+
+// phraseFiller — служебные слова, которые не влияют на правило.
+var phraseFiller = map[string]bool{
+	"каждый": true, "каждую": true, "каждое": true, "каждые": true, "каждого": true, "каждой": true,
+	"по": true, "в": true, "во": true, "на": true, "и": true, "раз": true,
+	"every": true, "each": true, "on": true, "the": true, "of": true, "in": true, "and": true, "a": true,
+}
+
+// phraseUnits — слова периода: день, неделя, месяц, год.
+var phraseUnits = map[string]string{
+	"день": unitDay, "дня": unitDay, "дней": unitDay, "дни": unitDay, "днем": unitDay, "днём": unitDay, "дням": unitDay,
+	"day": unitDay, "days": unitDay,
+	"неделя": unitWeek, "неделю": unitWeek, "недели": unitWeek, "недель": unitWeek,
+	"week": unitWeek, "weeks": unitWeek,
+	"месяц": unitMonth, "месяца": unitMonth, "месяцев": unitMonth,
+	"month": unitMonth, "months": unitMonth,
+	"год": unitYear, "года": unitYear, "лет": unitYear,
+	"year": unitYear, "years": unitYear,
+}
+
+// phraseAdverbs — наречия, которые сами задают период: "ежедневно", "weekly".
+var phraseAdverbs = map[string]string{
+	"ежедневно": unitDay, "daily": unitDay,
+	"еженедельно": unitWeek, "weekly": unitWeek,
+	"ежемесячно": unitMonth, "monthly": unitMonth,
+	"ежегодно": unitYear, "yearly": unitYear, "annually": unitYear,
+}
+
+var phraseOrdinals = map[string]int{
+	"первый": 1, "первую": 1, "первое": 1, "первого": 1, "first": 1,
+	"второй": 2, "вторую": 2, "второе": 2, "второго": 2, "second": 2,
+	"третий": 3, "третью": 3, "третье": 3, "третьего": 3, "third": 3,
+	"четвёртый": 4, "четвертый": 4, "четвёртую": 4, "четвертую": 4, "четвёртое": 4, "четвертое": 4,
+	"четвёртого": 4, "четвертого": 4, "fourth": 4,
+	"последний": -1, "последнюю": -1, "последнее": -1, "последнего": -1, "last": -1,
+	"предпоследний": -2, "предпоследнюю": -2, "предпоследнее": -2, "предпоследнего": -2,
+}
+
+var phraseNumbers = map[string]int{
+	"два": 2, "две": 2, "три": 3, "четыре": 4, "пять": 5, "шесть": 6, "семь": 7,
+	"восемь": 8, "девять": 9, "десять": 10,
+	"one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7,
+	"eight": 8, "nine": 9, "ten": 10,
+}
+
+// phraseWeekdays — основы названий дней недели, по которым узнаются все падежи.
+var phraseWeekdays = []struct {
+	stem    string
+	weekday time.Weekday
+}{
+	{"понедельник", time.Monday}, {"вторник", time.Tuesday}, {"сред", time.Wednesday},
+	{"четверг", time.Thursday}, {"пятниц", time.Friday}, {"суббот", time.Saturday},
+	{"воскресен", time.Sunday},
+	{"monday", time.Monday}, {"tuesday", time.Tuesday}, {"wednesday", time.Wednesday},
+	{"thursday", time.Thursday}, {"friday", time.Friday}, {"saturday", time.Saturday},
+	{"sunday", time.Sunday},
+}
+
+// phraseMonths — основы названий месяцев.
+var phraseMonths = []struct {
+	stem  string
+	month int
+}{
+	{"январ", 1}, {"феврал", 2}, {"март", 3}, {"апрел", 4}, {"мая", 5}, {"май", 5}, {"мае", 5},
+	{"июн", 6}, {"июл", 7}, {"август", 8}, {"сентябр", 9}, {"октябр", 10}, {"ноябр", 11}, {"декабр", 12},
+	{"january", 1}, {"february", 2}, {"march", 3}, {"april", 4}, {"may", 5}, {"june", 6},
+	{"july", 7}, {"august", 8}, {"september", 9}, {"october", 10}, {"november", 11}, {"december", 12},
+}
+
+// phraseToken — слово фразы и его позиция (номер символа от начала фразы).
+type phraseToken struct {
+	word string
+	pos  int
+}
+
+// phrase накапливает части правила, найденные во фразе.
+type phrase struct {
+	interval int
+	unit     string
+	business bool
+	// dayKind — дни недели заданы словом «будни» или «выходные»: тогда «дням»
+	// в "по будним дням" уточняет их, а не задаёт период
+	dayKind   bool
+	weekdays  []weekdayNum
+	monthDays []int
+	months    []int
+
+	// числа и порядковые числительные, которые ещё не отнесены к периоду или дню недели
+	numbers  []int
+	ordinals []int
+}
+
+// ParsePhrase переводит фразу на русском или английском языке ("каждый вторник
+// и четверг", "every 2 weeks", "последний день месяца") в правило повторения
+// в формате планировщика. Если у правила нет короткой записи, возвращается RRULE.
+// start — дата задачи: "каждые 2 недели" повторяются в её день недели,
+// а "каждый месяц" — в её день месяца.
+func ParsePhrase(text string, start time.Time) (string, error) {
+	tokens := tokenizePhrase(text)
+	if len(tokens) == 0 {
+		return "", fmt.Errorf("%w: phrase is empty", ErrInvalidPhrase)
+	}
+
+	var p phrase
+	for _, token := range tokens {
+		if err := p.consume(token); err != nil {
+			return "", err
+		}
+	}
+
+	repeat, err := p.rule(start)
+	if err != nil {
+		return "", err
+	}
+	if _, err := NextDate(time.Now(), time.Now().Format(Layout), repeat); err != nil {
+		return "", fmt.Errorf("%w: %q gives an invalid rule %q: %w", ErrInvalidPhrase, text, repeat, err)
+	}
+	return repeat, nil
+}
+
+// tokenizePhrase разбивает фразу на слова в нижнем регистре, запятые и точки отбрасываются.
+func tokenizePhrase(text string) []phraseToken {
+	var tokens []phraseToken
+	start := -1
+	runes := []rune(strings.ToLower(text))
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '-') {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, phraseToken{word: string(runes[start:i]), pos: start})
+			start = -1
+		}
+	}
+	return tokens
+}
+
+func (p *phrase) consume(token phraseToken) error {
+	word := token.word
+
+	if unit, ok := phraseAdverbs[word]; ok {
+		return p.setUnit(token, unit)
+	}
+	if phraseFiller[word] {
+		return nil
+	}
+	if word == "other" || word == "через" {
+		// "every other day", "через день" — через один период
+		p.numbers = append(p.numbers, 2)
+		return nil
+	}
+	if n, ok := phraseOrdinals[word]; ok {
+		p.ordinals = append(p.ordinals, n)
+		return nil
+	}
+	if n, ok := phraseNumbers[word]; ok {
+		p.numbers = append(p.numbers, n)
+		return nil
+	}
+	if n, ordinal, ok := parsePhraseNumber(word); ok {
+		if ordinal {
+			p.ordinals = append(p.ordinals, n)
+		} else {
+			p.numbers = append(p.numbers, n)
+		}
+		return nil
+	}
+
+	switch word {
+	case "рабочий", "рабочих", "рабочие", "рабочим", "working", "business":
+		p.business = true
+		return nil
+	case "будни", "будням", "будний", "будним", "будние", "weekday", "weekdays":
+		p.dayKind = true
+		return p.addWeekdays(token, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+	case "выходные", "выходным", "выходной", "weekend", "weekends":
+		p.dayKind = true
+		return p.addWeekdays(token, time.Saturday, time.Sunday)
+	case "число", "числа", "числам":
+		return p.takeMonthDays(token)
+	}
+
+	if unit, ok := phraseUnits[word]; ok {
+		return p.addUnit(token, unit)
+	}
+	for _, w := range phraseWeekdays {
+		if strings.HasPrefix(word, w.stem) || (len(word) == 3 && strings.HasPrefix(w.stem, word)) {
+			return p.addWeekdays(token, w.weekday)
+		}
+	}
+	for _, m := range phraseMonths {
+		if strings.HasPrefix(word, m.stem) || (len(word) == 3 && strings.HasPrefix(m.stem, word)) {
+			// "1 и 15 января" — числа перед месяцем относятся к дням месяца
+			if len(p.numbers) > 0 || len(p.ordinals) > 0 {
+				if err := p.takeMonthDays(token); err != nil {
+					return err
+				}
+			}
+			p.months = append(p.months, m.month)
+			return nil
+		}
+	}
+
+	return phraseError(token, "unknown word %q", word)
+}
+
+// phraseError — ошибка разбора фразы с указанием слова, на котором он остановился.
+func phraseError(token phraseToken, format string, args ...any) error {
+	return &RuleError{
+		Code:     "invalid_phrase",
+		Field:    "repeat_text",
+		Token:    token.word,
+		Position: token.pos + 1,
+		Err:      fmt.Errorf("%w: "+format, append([]any{ErrInvalidPhrase}, args...)...),
+	}
+}
+
+// parsePhraseNumber разбирает число, в том числе с окончанием порядкового
+// числительного: "15", "15-го", "15-е", "15th", "2nd".
+func parsePhraseNumber(word string) (int, bool, bool) {
+	digits := strings.TrimRightFunc(word, func(r rune) bool { return !unicode.IsDigit(r) })
+	if digits == "" {
+		return 0, false, false
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, false, false
+	}
+	switch strings.TrimPrefix(word[len(digits):], "-") {
+	case "":
+		return n, false, true
+	case "го", "е", "ое", "й", "ый", "ий", "st", "nd", "rd", "th":
+		return n, true, true
+	}
+	return 0, false, false
+}
+
+// addUnit относит накопленные числа к слову периода: "2 недели" — интервал,
+// "последний день" и "15-й день" — дни месяца, "рабочих дня" — рабочие дни.
+func (p *phrase) addUnit(token phraseToken, unit string) error {
+	if unit == unitDay && len(p.ordinals) > 0 {
+		p.monthDays = append(p.monthDays, p.ordinals...)
+		p.ordinals = nil
+		return nil
+	}
+	if unit == unitMonth && p.unit == "" && len(p.numbers) == 0 &&
+		(len(p.monthDays) > 0 || hasNthWeekday(p.weekdays)) {
+		// "последний день месяца", "второй вторник месяца" — месяц задаёт контекст, а не интервал
+		return nil
+	}
+	return p.setUnit(token, unit)
+}
+
+func (p *phrase) setUnit(token phraseToken, unit string) error {
+	if p.unit != "" && p.unit != unit {
+		return phraseError(token, "period %q conflicts with an earlier period", token.word)
+	}
+	p.unit = unit
+	switch len(p.numbers) {
+	case 0:
+	case 1:
+		if p.numbers[0] < 1 {
+			return phraseError(token, "interval before %q must be positive", token.word)
+		}
+		if p.interval != 0 {
+			return phraseError(token, "interval is given twice")
+		}
+		p.interval = p.numbers[0]
+		p.numbers = nil
+	default:
+		return phraseError(token, "several numbers before %q", token.word)
+	}
+	return nil
+}
+
+func (p *phrase) addWeekdays(token phraseToken, weekdays ...time.Weekday) error {
+	if len(p.numbers) > 0 {
+		return phraseError(token, "number %d before %q: use an ordinal like \"second\" or \"второй\"",
+			p.numbers[0], token.word)
+	}
+	for _, wd := range weekdays {
+		if len(p.ordinals) == 0 {
+			p.weekdays = append(p.weekdays, weekdayNum{weekday: wd})
+			continue
+		}
+		for _, n := range p.ordinals {
+			p.weekdays = append(p.weekdays, weekdayNum{n: n, weekday: wd})
+		}
+	}
+	p.ordinals = nil
+	return nil
+}
+
+func (p *phrase) takeMonthDays(token phraseToken) error {
+	days := append(p.numbers, p.ordinals...)
+	if len(days) == 0 {
+		return phraseError(token, "%q has no day number", token.word)
+	}
+	p.monthDays = append(p.monthDays, days...)
+	p.numbers, p.ordinals = nil, nil
+	return nil
+}
+
+func hasNthWeekday(days []weekdayNum) bool {
+	for _, d := range days {
+		if d.n != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// rule собирает правило из частей фразы, предпочитая короткий формат планировщика.
+func (p *phrase) rule(start time.Time) (string, error) {
+	// порядковые числительные без дня недели: "on the 15th", "15-го"
+	p.monthDays = append(p.monthDays, p.ordinals...)
+	if len(p.numbers) > 0 {
+		return "", fmt.Errorf("%w: number %d does not refer to a period, weekday or day of month", ErrInvalidPhrase, p.numbers[0])
+	}
+
+	interval := max(p.interval, 1)
+	switch {
+	case p.business:
+		if len(p.weekdays) > 0 || len(p.monthDays) > 0 || len(p.months) > 0 || (p.unit != "" && p.unit != unitDay) {
+			return "", fmt.Errorf("%w: working days cannot be combined with weekdays, dates or other periods", ErrInvalidPhrase)
+		}
+		return fmt.Sprintf("%s %d", businessDay, interval), nil
+
+	case len(p.weekdays) > 0:
+		if len(p.monthDays) > 0 {
+			return "", fmt.Errorf("%w: weekdays cannot be combined with days of month", ErrInvalidPhrase)
+		}
+		nth := hasNthWeekday(p.weekdays)
+		for _, d := range p.weekdays {
+			if (d.n != 0) != nth {
+				return "", fmt.Errorf("%w: weekdays with and without an ordinal cannot be mixed", ErrInvalidPhrase)
+			}
+		}
+		r := rrule{freq: freqWeekly, interval: interval, byDay: p.weekdays, byMonth: p.months}
+		if nth {
+			if p.unit != "" && p.unit != unitMonth {
+				return "", fmt.Errorf("%w: weekday ordinals need a monthly period, not %q", ErrInvalidPhrase, p.unit)
+			}
+			r.freq = freqMonthly
+		} else if p.unit != "" && p.unit != unitWeek && !(p.dayKind && p.unit == unitDay && p.interval == 0) {
+			return "", fmt.Errorf("%w: weekdays need a weekly period, not %q", ErrInvalidPhrase, p.unit)
+		}
+		return shortOrRRule(r), nil
+
+	case len(p.monthDays) > 0:
+		r := rrule{freq: freqMonthly, interval: interval, byMonthDay: p.monthDays, byMonth: p.months}
+		switch {
+		case p.unit == unitYear && len(p.months) > 0:
+			// "каждый год 1 января": раз в год — то же, что ежемесячно, но только в январе
+			if interval > 1 {
+				r.freq = freqYearly
+			}
+		case p.unit != "" && p.unit != unitMonth:
+			return "", fmt.Errorf("%w: days of month need a monthly period, not %q", ErrInvalidPhrase, p.unit)
+		}
+		return shortOrRRule(r), nil
+	}
+
+	if len(p.months) > 0 {
+		return "", fmt.Errorf("%w: months need a day of month or a weekday", ErrInvalidPhrase)
+	}
+	switch p.unit {
+	case unitDay:
+		return fmt.Sprintf("%s %d", day, interval), nil
+	case unitWeek:
+		// неделя отсчитывается от дня недели задачи: "каждые 2 недели" — w 5 2 для пятницы
+		weekday := (int(start.Weekday())+6)%7 + 1
+		switch {
+		case interval == 1:
+			return fmt.Sprintf("%s %d", week, weekday), nil
+		case interval <= maxWeekInterval:
+			return fmt.Sprintf("%s %d %d", week, weekday, interval), nil
+		}
+		return fmt.Sprintf("%s %d", day, interval*7), nil
+	case unitMonth:
+		if interval == 1 {
+			return fmt.Sprintf("%s %d", month, start.Day()), nil
+		}
+		// у правила m нет интервала в месяцах
+		return rrule{freq: freqMonthly, interval: interval}.String(), nil
+	case unitYear:
+		return shortOrRRule(rrule{freq: freqYearly, interval: interval}), nil
+	}
+	return "", fmt.Errorf("%w: no period found, say what repeats, e.g. \"every day\" or \"каждый понедельник\"", ErrInvalidPhrase)
+}
+
+// shortOrRRule записывает правило в коротком формате, если он есть, иначе как RRULE.
+func shortOrRRule(r rrule) string {
+	if short, err := FromRRule(r.String(), ""); err == nil {
+		return short
+	}
+	return r.String()
+}
+
+// PhraseLanguage определяет язык фразы: русский, если в ней есть кириллица.
+func PhraseLanguage(text string) string {
+	for _, r := range text {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return LangRu
+		}
+	}
+	return LangEn
+}
+
+// resolveRepeatText заменяет repeat_text задачи правилом повторения. Правило
+// считается от даты задачи, а без неё — от now.
+func resolveRepeatText(task *Task, now time.Time) error {
+	if task.RepeatText == "" {
+		return nil
+	}
+	if task.Repeat != "" {
+		return ErrRepeatAndRepeatText
+	}
+	start := now
+	if date, err := time.Parse(Layout, task.Date); err == nil {
+		start = date
+	}
+	repeat, err := ParsePhrase(task.RepeatText, start)
+	if err != nil {
+		return err
+	}
+	task.Repeat, task.RepeatText = repeat, ""
+	return nil
+}
@@ -0,0 +1,425 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NarthurN/TODO-API-web/pkg/calendar"
+)
+
+const (
+	// kindRRule — вид правила, записанного в формате RFC 5545.
+	kindRRule = "rrule"
+
+	// maxMonthSearch ограничивает поиск даты по правилу m: за 9 лет
+	// встречаются все сочетания дня и месяца, включая 29 февраля.
+	maxMonthSearch = 9 * 12
+
+	// maxWeekInterval и maxYearInterval — наибольшие интервалы правил w и y.
+	maxWeekInterval = 52
+	maxYearInterval = 100
+)
+
+// RepeatRule — разобранное правило повторения. ParseRepeat разбирает строку
+// один раз, а Next вычисляет следующую дату сразу, без перебора по одному шагу
+// от даты начала, поэтому время не зависит от того, как давно создана задача.
+type RepeatRule struct {
+	source string
+	kind   string
+
+	// days — интервал правил d и b.
+	days int
+	// interval — интервал в неделях или годах правил w и y. Неделя или год
+	// отсчитываются от даты начала задачи.
+	interval int
+	// weekdays — дни недели правила w, индекс — time.Weekday.
+	weekdays [7]bool
+	// monthDays — дни месяца правила m; lastDay и preLastDay — -1 и -2.
+	monthDays           []int
+	lastDay, preLastDay bool
+	// months — месяцы правила m; пустой список означает все месяцы.
+	months []int
+	// rrule — правила n и RRULE.
+	rrule rrule
+	// cron — правило, записанное cron-выражением.
+	cron cronRule
+
+	// shift — модификатор переноса с нерабочего дня, base — правило без него.
+	shift string
+	base  *RepeatRule
+}
+
+// ParseRepeat разбирает правило повторения в формате планировщика или RRULE.
+func ParseRepeat(repeat string) (*RepeatRule, error) {
+	if repeat == "" {
+		return nil, ErrInvalidRepeatParameter
+	}
+	r := &RepeatRule{source: repeat, interval: 1}
+
+	if IsRRule(repeat) {
+		rule, err := parseRRule(repeat)
+		if err != nil {
+			return nil, err
+		}
+		r.kind, r.rrule = kindRRule, rule
+		return r, nil
+	}
+
+	if IsCron(repeat) {
+		cron, err := parseCron(repeat)
+		if err != nil {
+			return nil, err
+		}
+		r.kind, r.cron = kindCron, cron
+		return r, nil
+	}
+
+	if base, shift, ok := cutShift(repeat); ok {
+		// перенос задаётся один раз: "d 7 > >" не допускается
+		if _, _, again := cutShift(base); again || !slices.Contains(shiftable, strings.Split(base, " ")[0]) {
+			return nil, ErrUnknownFormat
+		}
+		baseRule, err := ParseRepeat(base)
+		if err != nil {
+			return nil, err
+		}
+		r.kind, r.shift, r.base = baseRule.kind, shift, baseRule
+		return r, nil
+	}
+
+	repeatSlice := strings.Split(repeat, " ")
+	r.kind = repeatSlice[0]
+	var err error
+	switch r.kind {
+	case day:
+		r.days, err = parseDays(repeatSlice)
+	case year:
+		r.interval, err = parseYearInterval(repeatSlice)
+	case week:
+		err = r.parseWeekdays(repeatSlice)
+	case month:
+		err = r.parseMonthDays(repeatSlice)
+	case nthWeekday:
+		r.rrule, err = parseNthWeekday(repeatSlice)
+	case businessDay:
+		r.days, err = parseBusinessDays(repeatSlice)
+	case spaced:
+		if len(repeatSlice) != 1 {
+			return nil, ErrInvalidFormatSpaced
+		}
+		// без оценки задача повторяется на следующий день, как после первого повторения
+		r.days = 1
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func parseDays(repeatSlice []string) (int, error) {
+	if len(repeatSlice) != 2 {
+		return 0, ErrInvalidFormatInDay
+	}
+	days, err := strconv.Atoi(repeatSlice[1])
+	if err != nil || days < 1 {
+		return 0, ErrInvalidFormatInDay
+	}
+	if days > 400 {
+		return 0, ErrManyDays
+	}
+	return days, nil
+}
+
+// parseYearInterval разбирает правило "y" или "y 3" — каждые 3 года.
+func parseYearInterval(repeatSlice []string) (int, error) {
+	switch len(repeatSlice) {
+	case 1:
+		return 1, nil
+	case 2:
+		return parseInterval(repeatSlice[1], maxYearInterval, ErrInvalidYearInterval)
+	}
+	return 0, ErrUnknownFormat
+}
+
+// parseWeekdays разбирает правило "w 1,4" или "w 1,4 2" — каждые 2 недели.
+func (r *RepeatRule) parseWeekdays(repeatSlice []string) error {
+	if len(repeatSlice) < 2 || len(repeatSlice) > 3 {
+		return ErrInvalidRepeatParameter
+	}
+	for _, dayStr := range strings.Split(repeatSlice[1], ",") {
+		dayInt, err := strconv.Atoi(dayStr)
+		if err != nil || dayInt < 1 {
+			return ErrInvalidRepeatParameter
+		}
+		if dayInt > 7 {
+			return ErrManyWeeks
+		}
+		r.weekdays[dayInt%7] = true
+	}
+	if len(repeatSlice) == 3 {
+		var err error
+		r.interval, err = parseInterval(repeatSlice[2], maxWeekInterval, ErrInvalidWeekInterval)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseInterval(s string, max int, errInvalid error) (int, error) {
+	interval, err := strconv.Atoi(s)
+	if err != nil || interval < 1 || interval > max {
+		return 0, errInvalid
+	}
+	return interval, nil
+}
+
+func (r *RepeatRule) parseMonthDays(repeatSlice []string) error {
+	if len(repeatSlice) < 2 || len(repeatSlice) > 3 {
+		return ErrInvalidFormatInMonth
+	}
+	for _, dayStr := range strings.Split(repeatSlice[1], ",") {
+		dayInt, err := strconv.Atoi(dayStr)
+		if err != nil {
+			return ErrInvalidFormatInMonth
+		}
+		switch {
+		case dayInt == -1:
+			r.lastDay = true
+		case dayInt == -2:
+			r.preLastDay = true
+		case dayInt < 1 || dayInt > 31:
+			return ErrInvalidFormatInMonth
+		default:
+			r.monthDays = append(r.monthDays, dayInt)
+		}
+	}
+	slices.Sort(r.monthDays)
+
+	if len(repeatSlice) == 3 {
+		for _, monthStr := range strings.Split(repeatSlice[2], ",") {
+			monthInt, err := strconv.Atoi(monthStr)
+			if err != nil || monthInt < 1 {
+				return ErrInvalidFormatInMonth
+			}
+			if monthInt > 12 {
+				return ErrManyMonths
+			}
+			r.months = append(r.months, monthInt)
+		}
+	}
+	return nil
+}
+
+func (r *RepeatRule) String() string {
+	return r.source
+}
+
+// TimeOfDay возвращает время начала "HH:MM" из полей минуты и часа
+// cron-выражения, если оба заданы одним значением.
+func (r *RepeatRule) TimeOfDay() (string, bool) {
+	if r.kind != kindCron {
+		return "", false
+	}
+	return r.cron.timeOfDay()
+}
+
+// NextDate — Next для дат в формате планировщика. Если dstart содержит
+// время ("20240126 14:30"), оно сохраняется и в возвращаемой дате.
+func (r *RepeatRule) NextDate(now time.Time, dstart string) (string, error) {
+	if date, timeOfDay, ok := strings.Cut(dstart, " "); ok {
+		if _, err := time.Parse(TimeLayout, timeOfDay); err != nil {
+			return "", ErrInvalidTime
+		}
+		next, err := r.NextDate(now, date)
+		if err != nil {
+			return "", err
+		}
+		return next + " " + timeOfDay, nil
+	}
+
+	start, err := time.Parse(Layout, dstart)
+	if err != nil {
+		return "", fmt.Errorf("time.Parse: cannot parse dstart: %w", err)
+	}
+	next, err := r.Next(now, start)
+	if err != nil {
+		return "", err
+	}
+	return next.Format(Layout), nil
+}
+
+// Next возвращает следующую дату задачи с датой start после now.
+func (r *RepeatRule) Next(now, start time.Time) (time.Time, error) {
+	next, _, err := r.nextFrom(now, start, start)
+	return next, err
+}
+
+// nextFrom — то же, что Next, но правило с переносом с нерабочего дня
+// отсчитывает шаги от anchor — даты start до переноса. Вторым значением
+// возвращается следующая дата до переноса: от неё считается следующий шаг.
+func (r *RepeatRule) nextFrom(now, start, anchor time.Time) (time.Time, time.Time, error) {
+	if r.base != nil {
+		return r.nextShifted(now, start, anchor)
+	}
+	next, err := r.nextUnshifted(now, start)
+	return next, next, err
+}
+
+func (r *RepeatRule) nextUnshifted(now, start time.Time) (time.Time, error) {
+	switch r.kind {
+	case day, spaced:
+		return r.nextDay(now, start), nil
+	case year:
+		return r.nextYear(now, start), nil
+	case week:
+		return r.nextWeekday(now, start), nil
+	case month:
+		return r.nextMonthDay(now, start)
+	case businessDay:
+		return r.nextBusinessDay(now, start), nil
+	case kindCron:
+		return r.cron.next(now, start)
+	default:
+		return r.rrule.next(now, start)
+	}
+}
+
+// nextDay возвращает start + k*days для наименьшего k ≥ 1, при котором дата позже now.
+func (r *RepeatRule) nextDay(now, start time.Time) time.Time {
+	k := 1
+	if passed := daysBetween(start, now); passed >= 0 {
+		k = passed/r.days + 1
+	}
+	return start.AddDate(0, 0, k*r.days)
+}
+
+// nextYear переносит дату на interval лет вперёд, пока она раньше now. 29 февраля
+// в невисокосный год становится 1 марта, и дальше задача повторяется уже 1 марта.
+func (r *RepeatRule) nextYear(now, start time.Time) time.Time {
+	next := time.Date(start.Year()+r.interval, start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	if !next.Before(now) {
+		return next
+	}
+	// год кандидата не позже текущего и отстоит от next на кратное interval число лет
+	years := (now.Year() - next.Year()) / r.interval * r.interval
+	candidate := time.Date(next.Year()+years, next.Month(), next.Day(), 0, 0, 0, 0, start.Location())
+	if candidate.Before(now) {
+		candidate = candidate.AddDate(r.interval, 0, 0)
+	}
+	return candidate
+}
+
+// nextWeekday возвращает ближайший подходящий день недели позже start и now.
+// Подходят только недели, отстоящие от недели start на кратное interval число недель.
+func (r *RepeatRule) nextWeekday(now, start time.Time) time.Time {
+	from := start
+	if today := dateOf(now, start.Location()); today.After(from) {
+		from = today
+	}
+	anchor := weekStart(start)
+	next := from.AddDate(0, 0, 1)
+	for {
+		weeks := daysBetween(anchor, next) / 7
+		if skip := weeks % r.interval; skip != 0 {
+			next = anchor.AddDate(0, 0, 7*(weeks+r.interval-skip))
+		}
+		// правило содержит хотя бы один день, поэтому поиск заканчивается
+		// не позже второй подходящей недели
+		for end := weekStart(next).AddDate(0, 0, 7); next.Before(end); next = next.AddDate(0, 0, 1) {
+			if r.weekdays[next.Weekday()] {
+				return next
+			}
+		}
+	}
+}
+
+// nextMonthDay ищет первый подходящий день позже start и now, начиная с месяца
+// более поздней из них. Месяцы, целиком лежащие в прошлом, не перебираются.
+func (r *RepeatRule) nextMonthDay(now, start time.Time) (time.Time, error) {
+	from := start
+	if now.After(from) {
+		from = now
+	}
+	first := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, start.Location())
+
+	for i := 0; i < maxMonthSearch; i++ {
+		m := first.AddDate(0, i, 0)
+		if len(r.months) > 0 && !slices.Contains(r.months, int(m.Month())) {
+			continue
+		}
+		for _, d := range r.daysOfMonth(m) {
+			if t := m.AddDate(0, 0, d-1); t.After(from) {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, ErrNoOccurrence
+}
+
+// daysOfMonth возвращает отсортированные дни правила m, которые есть в месяце first.
+func (r *RepeatRule) daysOfMonth(first time.Time) []int {
+	last := first.AddDate(0, 1, -1).Day()
+	days := make([]int, 0, len(r.monthDays)+2)
+	for _, d := range r.monthDays {
+		if d <= last {
+			days = append(days, d)
+		}
+	}
+	if r.preLastDay {
+		days = append(days, last-1)
+	}
+	if r.lastDay {
+		days = append(days, last)
+	}
+	slices.Sort(days)
+	return days
+}
+
+// nextBusinessDay отсчитывает от start шаги по days рабочих дней. Рабочие дни,
+// прошедшие до now, считаются по календарю сразу, а не шагами.
+func (r *RepeatRule) nextBusinessDay(now, start time.Time) time.Time {
+	today := dateOf(now, start.Location())
+	if !today.After(start) {
+		return calendar.C.AddWorkdays(start, r.days)
+	}
+	passed := calendar.C.CountWorkdays(start, today)
+	return calendar.C.AddWorkdays(today, r.days-passed%r.days)
+}
+
+// nextShifted вычисляет дату по базовому правилу от anchor и переносит её на
+// ближайший рабочий день. Переносится только возвращаемая дата: шаги d и y
+// отсчитываются от даты до переноса, иначе каждый перенос сдвигал бы серию.
+func (r *RepeatRule) nextShifted(now, start, anchor time.Time) (time.Time, time.Time, error) {
+	raw, err := r.base.Next(now, anchor)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	for i := 0; i < maxShiftSteps; i++ {
+		t := calendar.C.NextWorkday(raw)
+		if r.shift == shiftBackward {
+			t = calendar.C.PrevWorkday(raw)
+		}
+		if t.After(now) && t.After(start) {
+			return t, raw, nil
+		}
+
+		raw, err = r.base.Next(raw, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	return time.Time{}, time.Time{}, ErrNoOccurrence
+}
+
+// dateOf возвращает полночь дня t в часовом поясе loc.
+func dateOf(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,640 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	rrulePrefix = "RRULE:"
+
+	freqDaily   = "DAILY"
+	freqWeekly  = "WEEKLY"
+	freqMonthly = "MONTHLY"
+	freqYearly  = "YEARLY"
+
+	// maxRRulePeriods ограничивает перебор периодов, чтобы правило,
+	// которое никогда не срабатывает (например, 30 февраля), не зациклило поиск.
+	maxRRulePeriods = 10000
+)
+
+// maxRRuleInterval — наибольший INTERVAL для каждой частоты. Ограничения
+// совпадают с короткими правилами d, w и y, месяцы ограничены тем же сроком,
+// что и годы, поэтому следующая дата всегда остаётся в формате YYYYMMDD.
+var maxRRuleInterval = map[string]int{
+	freqDaily:   400,
+	freqWeekly:  maxWeekInterval,
+	freqMonthly: 12 * maxYearInterval,
+	freqYearly:  maxYearInterval,
+}
+
+var (
+	ErrInvalidRRule     error = errors.New("rrule is incorrect")
+	ErrUnsupportedRRule error = errors.New("rrule part is not supported")
+	ErrNoOccurrence     error = errors.New("rule has no next occurrence")
+	ErrNotConvertible   error = errors.New("repeat cannot be converted")
+)
+
+// rruleDays — коды дней недели iCalendar, индекс совпадает с time.Weekday.
+var rruleDays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// weekdayNum — элемент BYDAY: день недели и, возможно, его порядковый номер
+// в периоде (2TU — второй вторник, -1FR — последняя пятница).
+type weekdayNum struct {
+	n       int
+	weekday time.Weekday
+}
+
+type rrule struct {
+	freq       string
+	interval   int
+	byDay      []weekdayNum
+	byMonthDay []int
+	byMonth    []int
+	// until и count — условия окончания серии; count отсчитывается от даты начала.
+	until time.Time
+	count int
+}
+
+// IsRRule сообщает, записано ли правило повторения в формате RFC 5545.
+func IsRRule(repeat string) bool {
+	upper := strings.ToUpper(strings.TrimSpace(repeat))
+	return strings.HasPrefix(upper, rrulePrefix) || strings.HasPrefix(upper, "FREQ=")
+}
+
+func parseRRule(s string) (rrule, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(strings.ToUpper(s), rrulePrefix) {
+		s = s[len(rrulePrefix):]
+	}
+
+	r := rrule{interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || name == "" || value == "" {
+			return rrule{}, fmt.Errorf("%w: %q", ErrInvalidRRule, part)
+		}
+		if seen[name] {
+			return rrule{}, fmt.Errorf("%w: duplicate %s", ErrInvalidRRule, name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			switch value {
+			case freqDaily, freqWeekly, freqMonthly, freqYearly:
+				r.freq = value
+			default:
+				return rrule{}, fmt.Errorf("%w: FREQ=%s", ErrUnsupportedRRule, value)
+			}
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(value)
+			if err != nil || r.interval < 1 {
+				return rrule{}, fmt.Errorf("%w: INTERVAL=%s", ErrInvalidRRule, value)
+			}
+		case "BYDAY":
+			r.byDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.byMonthDay, err = parseIntList(value, -31, 31)
+		case "BYMONTH":
+			r.byMonth, err = parseIntList(value, 1, 12)
+		case "UNTIL":
+			// время в UNTIL отбрасывается: планировщик работает с датами
+			if len(value) < len(Layout) {
+				return rrule{}, fmt.Errorf("%w: UNTIL=%s", ErrInvalidRRule, value)
+			}
+			r.until, err = time.Parse(Layout, value[:len(Layout)])
+			if err != nil {
+				return rrule{}, fmt.Errorf("%w: UNTIL=%s", ErrInvalidRRule, value)
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(value)
+			if err != nil || r.count < 1 || r.count > maxRRulePeriods {
+				return rrule{}, fmt.Errorf("%w: COUNT=%s", ErrInvalidRRule, value)
+			}
+		case "WKST":
+			if value != "MO" {
+				return rrule{}, fmt.Errorf("%w: WKST=%s", ErrUnsupportedRRule, value)
+			}
+		default:
+			return rrule{}, fmt.Errorf("%w: %s", ErrUnsupportedRRule, name)
+		}
+		if err != nil {
+			return rrule{}, fmt.Errorf("%w: %s=%s", err, name, value)
+		}
+	}
+
+	if r.freq == "" {
+		return rrule{}, fmt.Errorf("%w: FREQ is required", ErrInvalidRRule)
+	}
+	if r.interval > maxRRuleInterval[r.freq] {
+		return rrule{}, fmt.Errorf("%w: INTERVAL=%d is more than %d for %s", ErrInvalidRRule, r.interval, maxRRuleInterval[r.freq], r.freq)
+	}
+	if r.freq == freqWeekly || r.freq == freqDaily {
+		for _, d := range r.byDay {
+			if d.n != 0 {
+				return rrule{}, fmt.Errorf("%w: BYDAY with position requires MONTHLY or YEARLY", ErrInvalidRRule)
+			}
+		}
+	}
+	if r.count > 0 && !r.until.IsZero() {
+		return rrule{}, fmt.Errorf("%w: COUNT and UNTIL are mutually exclusive", ErrInvalidRRule)
+	}
+	if r.freq == freqWeekly && len(r.byMonthDay) > 0 {
+		return rrule{}, fmt.Errorf("%w: BYMONTHDAY is not allowed with WEEKLY", ErrInvalidRRule)
+	}
+
+	return r, nil
+}
+
+func parseByDay(value string) ([]weekdayNum, error) {
+	var days []weekdayNum
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, ErrInvalidRRule
+		}
+		code := item[len(item)-2:]
+		wd := slices.Index(rruleDays, code)
+		if wd < 0 {
+			return nil, ErrInvalidRRule
+		}
+		var n int
+		if num := item[:len(item)-2]; num != "" {
+			var err error
+			n, err = strconv.Atoi(num)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, ErrInvalidRRule
+			}
+		}
+		days = append(days, weekdayNum{n: n, weekday: time.Weekday(wd)})
+	}
+	return days, nil
+}
+
+func parseIntList(value string, min, max int) ([]int, error) {
+	var ints []int
+	for _, item := range strings.Split(value, ",") {
+		v, err := strconv.Atoi(item)
+		if err != nil || v == 0 || v < min || v > max {
+			return nil, ErrInvalidRRule
+		}
+		ints = append(ints, v)
+	}
+	return ints, nil
+}
+
+// String возвращает правило в каноническом виде без префикса "RRULE:".
+func (r rrule) String() string {
+	parts := []string{"FREQ=" + r.freq}
+	if r.interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.interval))
+	}
+	if len(r.byDay) > 0 {
+		days := make([]string, 0, len(r.byDay))
+		for _, d := range r.byDay {
+			code := rruleDays[d.weekday]
+			if d.n != 0 {
+				code = strconv.Itoa(d.n) + code
+			}
+			days = append(days, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.byMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.byMonthDay))
+	}
+	if len(r.byMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.byMonth))
+	}
+	if !r.until.IsZero() {
+		parts = append(parts, "UNTIL="+r.until.Format(Layout))
+	}
+	if r.count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.count))
+	}
+	return strings.Join(parts, ";")
+}
+
+// next возвращает первое вхождение правила строго после start и now
+// с учётом UNTIL и COUNT. Дата начала считается первым вхождением серии.
+func (r rrule) next(now, start time.Time) (time.Time, error) {
+	if r.count == 0 {
+		next, err := r.following(now, start)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if !r.until.IsZero() && next.After(r.until) {
+			return time.Time{}, ErrSeriesEnded
+		}
+		return next, nil
+	}
+
+	t := start
+	for i := 1; i < r.count; i++ {
+		var err error
+		t, err = r.following(t, start)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if t.After(now) {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrSeriesEnded
+}
+
+// following возвращает первое вхождение правила строго после start и now без
+// учёта условий окончания. Периоды, целиком лежащие до этой границы,
+// пропускаются арифметически.
+func (r rrule) following(now, start time.Time) (time.Time, error) {
+	after := start
+	if now.After(after) {
+		after = now
+	}
+
+	var k int
+	switch r.freq {
+	case freqDaily:
+		k = daysBetween(start, after) / r.interval
+	case freqWeekly:
+		k = daysBetween(weekStart(start), after) / (7 * r.interval)
+	case freqMonthly:
+		k = monthsBetween(start, after) / r.interval
+	case freqYearly:
+		k = (after.Year() - start.Year()) / r.interval
+	}
+	if k < 0 {
+		k = 0
+	}
+
+	for i := 0; i < maxRRulePeriods; i++ {
+		for _, candidate := range r.period(start, k+i) {
+			if candidate.After(after) {
+				return candidate, nil
+			}
+		}
+	}
+
+	return time.Time{}, ErrNoOccurrence
+}
+
+// period возвращает отсортированные даты k-го периода правила.
+func (r rrule) period(start time.Time, k int) []time.Time {
+	loc := start.Location()
+	var dates []time.Time
+
+	switch r.freq {
+	case freqDaily:
+		d := start.AddDate(0, 0, k*r.interval)
+		if r.matches(d) {
+			dates = append(dates, d)
+		}
+	case freqWeekly:
+		ws := weekStart(start).AddDate(0, 0, 7*k*r.interval)
+		weekdays := []time.Weekday{start.Weekday()}
+		if len(r.byDay) > 0 {
+			weekdays = weekdays[:0]
+			for _, d := range r.byDay {
+				weekdays = append(weekdays, d.weekday)
+			}
+		}
+		for _, wd := range weekdays {
+			d := ws.AddDate(0, 0, (int(wd)+6)%7)
+			if r.inMonths(d) {
+				dates = append(dates, d)
+			}
+		}
+	case freqMonthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(k*r.interval), 1, 0, 0, 0, 0, loc)
+		if r.inMonths(first) {
+			dates = r.monthDays(first, start.Day())
+		}
+	case freqYearly:
+		year := start.Year() + k*r.interval
+		switch {
+		case len(r.byMonth) > 0:
+			for _, m := range r.byMonth {
+				dates = append(dates, r.monthDays(time.Date(year, time.Month(m), 1, 0, 0, 0, 0, loc), start.Day())...)
+			}
+		case len(r.byMonthDay) > 0:
+			for m := time.January; m <= time.December; m++ {
+				dates = append(dates, r.monthDays(time.Date(year, m, 1, 0, 0, 0, 0, loc), start.Day())...)
+			}
+		case len(r.byDay) > 0:
+			jan1 := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+			dates = weekdaysIn(jan1, jan1.AddDate(1, 0, 0), r.byDay)
+		default:
+			d := time.Date(year, start.Month(), start.Day(), 0, 0, 0, 0, loc)
+			if d.Day() == start.Day() {
+				dates = append(dates, d)
+			}
+		}
+	}
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return slices.CompactFunc(dates, func(a, b time.Time) bool { return a.Equal(b) })
+}
+
+// monthDays возвращает дни месяца, начинающегося с first, по BYMONTHDAY и BYDAY.
+// Если оба списка пусты, используется день месяца из даты начала.
+func (r rrule) monthDays(first time.Time, startDay int) []time.Time {
+	next := first.AddDate(0, 1, 0)
+	last := next.AddDate(0, 0, -1).Day()
+
+	var byMonthDay []time.Time
+	for _, md := range r.byMonthDay {
+		day := md
+		if md < 0 {
+			day = last + md + 1
+		}
+		if day >= 1 && day <= last {
+			byMonthDay = append(byMonthDay, first.AddDate(0, 0, day-1))
+		}
+	}
+
+	switch {
+	case len(r.byDay) > 0 && len(r.byMonthDay) > 0:
+		var dates []time.Time
+		for _, d := range weekdaysIn(first, next, r.byDay) {
+			if slices.ContainsFunc(byMonthDay, d.Equal) {
+				dates = append(dates, d)
+			}
+		}
+		return dates
+	case len(r.byDay) > 0:
+		return weekdaysIn(first, next, r.byDay)
+	case len(r.byMonthDay) > 0:
+		return byMonthDay
+	case startDay <= last:
+		return []time.Time{first.AddDate(0, 0, startDay-1)}
+	}
+	return nil
+}
+
+func (r rrule) inMonths(t time.Time) bool {
+	return len(r.byMonth) == 0 || slices.Contains(r.byMonth, int(t.Month()))
+}
+
+func (r rrule) matches(t time.Time) bool {
+	if !r.inMonths(t) {
+		return false
+	}
+	if len(r.byMonthDay) > 0 {
+		last := LastDayOfMonth(t).Day()
+		ok := false
+		for _, md := range r.byMonthDay {
+			if md == t.Day() || (md < 0 && last+md+1 == t.Day()) {
+				ok = true
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(r.byDay) > 0 {
+		return slices.ContainsFunc(r.byDay, func(d weekdayNum) bool { return d.weekday == t.Weekday() })
+	}
+	return true
+}
+
+// weekdaysIn возвращает дни из полуинтервала [from, to), подходящие под BYDAY.
+// Номер в BYDAY отсчитывается от начала (или от конца, если он отрицательный) этого интервала.
+func weekdaysIn(from, to time.Time, byDay []weekdayNum) []time.Time {
+	var dates []time.Time
+	for _, d := range byDay {
+		var all []time.Time
+		offset := (int(d.weekday) - int(from.Weekday()) + 7) % 7
+		for t := from.AddDate(0, 0, offset); t.Before(to); t = t.AddDate(0, 0, 7) {
+			all = append(all, t)
+		}
+		switch {
+		case d.n == 0:
+			dates = append(dates, all...)
+		case d.n > 0 && d.n <= len(all):
+			dates = append(dates, all[d.n-1])
+		case d.n < 0 && -d.n <= len(all):
+			dates = append(dates, all[len(all)+d.n])
+		}
+	}
+	return dates
+}
+
+func weekStart(t time.Time) time.Time {
+	return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+}
+
+func daysBetween(from, to time.Time) int {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
+
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
+
+func joinInts(ints []int) string {
+	strs := make([]string, 0, len(ints))
+	for _, v := range ints {
+		strs = append(strs, strconv.Itoa(v))
+	}
+	return strings.Join(strs, ",")
+}
+
+// ToRRule переводит правило в формате планировщика (d, y, w, m, n) в RRULE.
+// Для cron-выражений возвращается ErrNotConvertible. date — дата задачи
+// YYYYMMDD или пустая строка, если она неизвестна (см. leapDayYearly).
+// Обратное преобразование выполняет FromRRule.
+func ToRRule(repeat, date string) (string, error) {
+	var r rrule
+	var err error
+	if IsRRule(repeat) {
+		r, err = parseRRule(repeat)
+	} else {
+		r, err = parseShort(repeat)
+		if err == nil && r.leapDayYearly(date) {
+			return "", ErrNotConvertible
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+// leapDayYearly сообщает, что r — простое ежегодное правило, а date — 29 февраля.
+// Для такой даты y и FREQ=YEARLY расходятся: y в невисокосный год переносит
+// задачу на 1 марта, а RRULE такие годы пропускает, поэтому правило не переводится.
+func (r rrule) leapDayYearly(date string) bool {
+	plain := r.freq == freqYearly && len(r.byDay) == 0 && len(r.byMonthDay) == 0 && len(r.byMonth) == 0
+	return plain && strings.HasSuffix(date, "0229")
+}
+
+// parseShort разбирает правило планировщика d, y, w, m или n в rrule.
+// Для правил без аналога в RRULE возвращается ErrNotConvertible.
+func parseShort(repeat string) (rrule, error) {
+	if _, _, ok := cutShift(repeat); ok {
+		// в RRULE нет переноса с нерабочих дней
+		return rrule{}, ErrNotConvertible
+	}
+
+	if IsCron(repeat) {
+		if _, err := parseCron(repeat); err != nil {
+			return rrule{}, err
+		}
+		// в RRULE нет времени начала, а дни месяца и недели в cron объединяются через «или»
+		return rrule{}, ErrNotConvertible
+	}
+
+	repeatSlice := strings.Split(repeat, " ")
+	r := rrule{interval: 1}
+	switch repeatSlice[0] {
+	case "":
+		return rrule{}, ErrInvalidRepeatParameter
+	case businessDay:
+		if _, err := parseBusinessDays(repeatSlice); err != nil {
+			return rrule{}, err
+		}
+		return rrule{}, ErrNotConvertible
+	case spaced:
+		if len(repeatSlice) != 1 {
+			return rrule{}, ErrInvalidFormatSpaced
+		}
+		// интервал зависит от оценок и не выражается в RRULE
+		return rrule{}, ErrNotConvertible
+	case day:
+		if len(repeatSlice) != 2 {
+			return rrule{}, ErrInvalidFormatInDay
+		}
+		days, err := strconv.Atoi(repeatSlice[1])
+		if err != nil || days < 1 {
+			return rrule{}, ErrInvalidFormatInDay
+		}
+		if days > 400 {
+			return rrule{}, ErrManyDays
+		}
+		r.freq, r.interval = freqDaily, days
+	case year:
+		interval, err := parseYearInterval(repeatSlice)
+		if err != nil {
+			return rrule{}, err
+		}
+		r.freq, r.interval = freqYearly, interval
+	case week:
+		if len(repeatSlice) < 2 || len(repeatSlice) > 3 {
+			return rrule{}, ErrInvalidRepeatParameter
+		}
+		if len(repeatSlice) == 3 {
+			interval, err := parseInterval(repeatSlice[2], maxWeekInterval, ErrInvalidWeekInterval)
+			if err != nil {
+				return rrule{}, err
+			}
+			r.interval = interval
+		}
+		r.freq = freqWeekly
+		for _, dayStr := range strings.Split(repeatSlice[1], ",") {
+			dayInt, err := strconv.Atoi(dayStr)
+			if err != nil || dayInt < 1 {
+				return rrule{}, ErrInvalidRepeatParameter
+			}
+			if dayInt > 7 {
+				return rrule{}, ErrManyWeeks
+			}
+			r.byDay = append(r.byDay, weekdayNum{weekday: time.Weekday(dayInt % 7)})
+		}
+	case month:
+		if len(repeatSlice) < 2 || len(repeatSlice) > 3 {
+			return rrule{}, ErrInvalidFormatInMonth
+		}
+		r.freq = freqMonthly
+		for _, dayStr := range strings.Split(repeatSlice[1], ",") {
+			dayInt, err := strconv.Atoi(dayStr)
+			if err != nil || dayInt == 0 || dayInt < -2 || dayInt > 31 {
+				return rrule{}, ErrInvalidFormatInMonth
+			}
+			r.byMonthDay = append(r.byMonthDay, dayInt)
+		}
+		if len(repeatSlice) == 3 {
+			for _, monthStr := range strings.Split(repeatSlice[2], ",") {
+				monthInt, err := strconv.Atoi(monthStr)
+				if err != nil || monthInt < 1 {
+					return rrule{}, ErrInvalidFormatInMonth
+				}
+				if monthInt > 12 {
+					return rrule{}, ErrManyMonths
+				}
+				r.byMonth = append(r.byMonth, monthInt)
+			}
+		}
+	case nthWeekday:
+		return parseNthWeekday(repeatSlice)
+	default:
+		return rrule{}, ErrUnknownFormat
+	}
+
+	return r, nil
+}
+
+// FromRRule переводит RRULE в правило планировщика. Если у правила нет
+// точного аналога в коротком формате для задачи с датой date, возвращается
+// ErrNotConvertible. Пустая date означает, что дата задачи неизвестна.
+func FromRRule(rule, date string) (string, error) {
+	r, err := parseRRule(rule)
+	if err != nil {
+		return "", err
+	}
+	if r.leapDayYearly(date) {
+		return "", ErrNotConvertible
+	}
+	if r.count > 0 || !r.until.IsZero() {
+		// условия окончания хранятся в задаче отдельно от короткого правила
+		return "", ErrNotConvertible
+	}
+
+	switch {
+	case r.freq == freqDaily && len(r.byDay) == 0 && len(r.byMonthDay) == 0 && len(r.byMonth) == 0:
+		return fmt.Sprintf("%s %d", day, r.interval), nil
+	case r.freq == freqYearly && len(r.byDay) == 0 && len(r.byMonthDay) == 0 && len(r.byMonth) == 0:
+		if r.interval > 1 {
+			return fmt.Sprintf("%s %d", year, r.interval), nil
+		}
+		return year, nil
+	case r.freq == freqWeekly && len(r.byDay) > 0 && len(r.byMonth) == 0:
+		days := make([]int, 0, len(r.byDay))
+		for _, d := range r.byDay {
+			days = append(days, (int(d.weekday)+6)%7+1)
+		}
+		short := fmt.Sprintf("%s %s", week, joinInts(days))
+		if r.interval > 1 {
+			short += " " + strconv.Itoa(r.interval)
+		}
+		return short, nil
+	case r.freq == freqMonthly && r.interval == 1 && len(r.byDay) == 0 && len(r.byMonthDay) > 0:
+		for _, md := range r.byMonthDay {
+			if md < -2 {
+				return "", ErrNotConvertible
+			}
+		}
+		short := fmt.Sprintf("%s %s", month, joinInts(r.byMonthDay))
+		if len(r.byMonth) > 0 {
+			short += " " + joinInts(r.byMonth)
+		}
+		return short, nil
+	case r.isNthWeekday():
+		return r.nthWeekdayString(), nil
+	}
+
+	return "", ErrNotConvertible
+}
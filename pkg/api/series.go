@@ -0,0 +1,271 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxOccurrences ограничивает размер серии, которую возвращает Occurrences.
+	MaxOccurrences = 1000
+
+	// maxOccurrenceSteps ограничивает перебор дат до начала окна from.
+	maxOccurrenceSteps = 100000
+)
+
+var (
+	ErrInvalidCount     error = errors.New("count must be from 1 to 1000")
+	ErrInvalidWindow    error = errors.New("date window is incorrect")
+	ErrNoLimit          error = errors.New("count or date window is required")
+	ErrSeriesEnded      error = errors.New("series of repeats has ended")
+	ErrInvalidUntil     error = errors.New("until is in invalid format")
+	ErrInvalidRemaining error = errors.New("remaining must not be negative")
+	ErrEndWithoutRepeat error = errors.New("until and remaining require repeat")
+
+	ErrInvalidExdate        error = errors.New("exdate is in invalid format")
+	ErrExdatesWithoutRepeat error = errors.New("exdates require repeat")
+	ErrExdateNotFound       error = errors.New("date is not excluded")
+)
+
+// Series — правило повторения вместе с условиями окончания серии.
+type Series struct {
+	Repeat string
+	// Until — последняя допустимая дата серии в формате YYYYMMDD.
+	Until string
+	// Remaining — сколько повторений осталось, включая текущее; 0 — без ограничения.
+	Remaining int
+	// Exdates — пропускаемые даты серии в формате YYYYMMDD. Пропущенная дата
+	// не расходует Remaining.
+	Exdates []string
+	// Anchor — дата YYYYMMDD, с которой правило с переносом (d 7 >) перенесло
+	// текущую дату задачи на рабочий день; пустая строка — дата не переносилась.
+	// Следующие даты считаются от неё, чтобы серия не сдвигалась.
+	Anchor string
+}
+
+func (t *Task) Series() Series {
+	return Series{
+		Repeat:    t.Repeat,
+		Until:     t.Until,
+		Remaining: t.Remaining,
+		Exdates:   t.Exdates,
+		Anchor:    t.Anchor,
+	}
+}
+
+// normalize проверяет условия окончания и переносит COUNT и UNTIL из RRULE
+// в поля серии: дата задачи сдвигается при каждом выполнении, поэтому COUNT,
+// отсчитываемый от даты начала, нельзя оставлять внутри правила.
+func (s Series) normalize() (Series, error) {
+	if s.Until != "" {
+		if _, err := time.Parse(Layout, s.Until); err != nil {
+			return s, ErrInvalidUntil
+		}
+	}
+	if s.Remaining < 0 {
+		return s, ErrInvalidRemaining
+	}
+	if s.Repeat == "" && (s.Until != "" || s.Remaining > 0) {
+		return s, ErrEndWithoutRepeat
+	}
+	if len(s.Exdates) > 0 {
+		if s.Repeat == "" {
+			return s, ErrExdatesWithoutRepeat
+		}
+		exdates := make([]string, 0, len(s.Exdates))
+		for _, date := range s.Exdates {
+			if _, err := time.Parse(Layout, date); err != nil {
+				return s, fmt.Errorf("%w: %q", ErrInvalidExdate, date)
+			}
+			exdates = append(exdates, date)
+		}
+		slices.Sort(exdates)
+		s.Exdates = slices.Compact(exdates)
+	}
+	if !IsRRule(s.Repeat) {
+		return s, nil
+	}
+
+	r, err := parseRRule(s.Repeat)
+	if err != nil {
+		return s, err
+	}
+	if !r.until.IsZero() {
+		if until := r.until.Format(Layout); s.Until == "" || until < s.Until {
+			s.Until = until
+		}
+	}
+	if r.count > 0 && (s.Remaining == 0 || r.count < s.Remaining) {
+		s.Remaining = r.count
+	}
+	r.until, r.count = time.Time{}, 0
+	s.Repeat = r.String()
+
+	return s, nil
+}
+
+// Next возвращает дату, на которую переносится задача с датой dstart после
+// выполнения, или ErrSeriesEnded, если серия на этой дате заканчивается.
+func (s Series) Next(now time.Time, dstart string) (string, error) {
+	next, _, _, err := s.next(now, dstart)
+	return next, err
+}
+
+// next, кроме даты, возвращает Anchor для неё (см. Series.Anchor) и число
+// повторений, пройденных от dstart до неё: пропущенные даты просроченной задачи
+// тоже расходуют Remaining. Если dstart содержит время ("20240126 14:30"),
+// оно сохраняется и в возвращаемой дате.
+func (s Series) next(now time.Time, dstart string) (string, string, int, error) {
+	date, timeOfDay, hasTime := strings.Cut(dstart, " ")
+	if hasTime {
+		if _, err := time.Parse(TimeLayout, timeOfDay); err != nil {
+			return "", "", 0, ErrInvalidTime
+		}
+	}
+	start, err := time.Parse(Layout, date)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("time.Parse: cannot parse dstart: %w", err)
+	}
+	anchor := start
+	if s.Anchor != "" {
+		if anchor, err = time.Parse(Layout, s.Anchor); err != nil {
+			return "", "", 0, fmt.Errorf("time.Parse: cannot parse anchor: %w", err)
+		}
+	}
+	rule, err := ParseRepeat(s.Repeat)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	next, raw, steps, err := s.nextAfter(rule, now, start, anchor)
+	if err != nil {
+		return "", "", 0, err
+	}
+	result := next.Format(Layout)
+	if hasTime {
+		result += " " + timeOfDay
+	}
+	nextAnchor := ""
+	if !raw.Equal(next) {
+		nextAnchor = raw.Format(Layout)
+	}
+	return result, nextAnchor, steps, nil
+}
+
+func (s Series) nextAfter(rule *RepeatRule, now, start, anchor time.Time) (time.Time, time.Time, int, error) {
+	// каждая исключённая дата пропускается не больше одного раза
+	skips := len(s.Exdates)
+
+	if s.Remaining == 0 {
+		next, raw, err := rule.nextFrom(now, start, anchor)
+		for ; err == nil && skips > 0 && s.excluded(next); skips-- {
+			next, raw, err = rule.nextFrom(next, next, raw)
+		}
+		if err != nil {
+			return time.Time{}, time.Time{}, 0, err
+		}
+		if s.ended(next) {
+			return time.Time{}, time.Time{}, 0, ErrSeriesEnded
+		}
+		return next, raw, 1, nil
+	}
+
+	prev, prevRaw := start, anchor
+	for steps := 1; steps < s.Remaining; {
+		next, raw, err := rule.nextFrom(prev, prev, prevRaw)
+		if err != nil {
+			return time.Time{}, time.Time{}, 0, err
+		}
+		if s.ended(next) {
+			return time.Time{}, time.Time{}, 0, ErrSeriesEnded
+		}
+		prev, prevRaw = next, raw
+		if skips > 0 && s.excluded(next) {
+			skips--
+			continue
+		}
+		if next.After(now) {
+			return next, raw, steps, nil
+		}
+		steps++
+	}
+	return time.Time{}, time.Time{}, 0, ErrSeriesEnded
+}
+
+func (s Series) excluded(t time.Time) bool {
+	_, ok := slices.BinarySearch(s.Exdates, t.Format(Layout))
+	return ok
+}
+
+func (s Series) ended(t time.Time) bool {
+	return s.Until != "" && t.Format(Layout) > s.Until
+}
+
+// Occurrences возвращает даты, на которые задача с датой dstart будет
+// переноситься одна за другой. Серия ограничивается количеством count
+// и/или окном [from, to]; нулевое значение параметра означает, что
+// ограничения нет, но хотя бы count или to должны быть заданы.
+// Даты считаются тем же Series.Next, что и при выполнении задачи.
+func Occurrences(dstart string, series Series, count int, from, to time.Time) ([]string, error) {
+	if _, err := time.Parse(Layout, dstart); err != nil {
+		return nil, ErrInvalidDate
+	}
+	if count < 0 || count > MaxOccurrences {
+		return nil, ErrInvalidCount
+	}
+	if count == 0 && to.IsZero() {
+		return nil, ErrNoLimit
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return nil, ErrInvalidWindow
+	}
+	if count == 0 {
+		count = MaxOccurrences
+	}
+
+	series, err := series.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	dates := make([]string, 0)
+	prev := dstart
+	for i := 0; i < maxOccurrenceSteps && len(dates) < count; i++ {
+		t, err := time.Parse(Layout, prev)
+		if err != nil {
+			return nil, fmt.Errorf("time.Parse: cannot parse date: %w", err)
+		}
+		next, anchor, _, err := series.next(t, prev)
+		if errors.Is(err, ErrSeriesEnded) || errors.Is(err, ErrNoOccurrence) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if next <= prev {
+			return nil, fmt.Errorf("NextDate: rule %q does not advance after %s", series.Repeat, prev)
+		}
+		if series.Remaining > 0 {
+			series.Remaining--
+		}
+		// следующая дата считается от даты до переноса, а не от перенесённой
+		series.Anchor = anchor
+
+		t, err = time.Parse(Layout, next)
+		if err != nil {
+			return nil, fmt.Errorf("time.Parse: cannot parse next date: %w", err)
+		}
+		if !to.IsZero() && t.After(to) {
+			break
+		}
+		if from.IsZero() || !t.Before(from) {
+			dates = append(dates, next)
+		}
+		prev = next
+	}
+
+	return dates, nil
+}
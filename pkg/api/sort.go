@@ -0,0 +1,48 @@
+package api
+
+import "errors"
+
+// maxPriority — наибольший приоритет задачи; 0 — приоритет не задан.
+const maxPriority = 3
+
+// Поля, по которым сортируется список задач. SortCreated — порядок создания.
+const (
+	SortDate     = "date"
+	SortPriority = "priority"
+	SortTitle    = "title"
+	SortCreated  = "created"
+)
+
+var (
+	ErrInvalidPriority error = errors.New("priority must be from 0 to 3")
+	ErrInvalidSort     error = errors.New("sort must be date, priority, title or created")
+	ErrInvalidOrder    error = errors.New("order must be asc or desc")
+)
+
+// TaskOrder — порядок списка задач: поле сортировки и направление.
+type TaskOrder struct {
+	Field string
+	Desc  bool
+}
+
+// ParseTaskOrder разбирает параметры sort и order. По умолчанию задачи
+// идут по дате, ближайшие первыми.
+func ParseTaskOrder(sort, order string) (TaskOrder, error) {
+	o := TaskOrder{Field: SortDate}
+	switch sort {
+	case "":
+	case SortDate, SortPriority, SortTitle, SortCreated:
+		o.Field = sort
+	default:
+		return TaskOrder{}, ErrInvalidSort
+	}
+
+	switch order {
+	case "", "asc":
+	case "desc":
+		o.Desc = true
+	default:
+		return TaskOrder{}, ErrInvalidOrder
+	}
+	return o, nil
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	// spaced — интервальное повторение по алгоритму SM-2: "s". Следующая дата
+	// зависит от оценки, с которой задача отмечена выполненной.
+	spaced = "s"
+
+	// initialEase — коэффициент лёгкости новой задачи, minEase — наименьший.
+	initialEase = 2.5
+	minEase     = 1.3
+
+	// maxGrade — наибольшая оценка; оценка ниже passingGrade начинает повторения заново.
+	maxGrade     = 5
+	passingGrade = 3
+)
+
+var (
+	ErrInvalidGrade        error = errors.New("grade must be from 0 to 5")
+	ErrInvalidSpacedState  error = errors.New("ease must be at least 1.3, interval and repetitions must not be negative")
+	ErrInvalidFormatSpaced error = errors.New("format of spaced repetition is incorrect")
+)
+
+// IsSpaced сообщает, повторяется ли задача по оценкам (правило s).
+func IsSpaced(repeat string) bool {
+	return repeat == spaced
+}
+
+// ParseGrade разбирает оценку выполнения задачи: от 0 (не вспомнил) до 5 (легко).
+func ParseGrade(s string) (int, error) {
+	grade, err := strconv.Atoi(s)
+	if err != nil || grade < 0 || grade > maxGrade {
+		return 0, ErrInvalidGrade
+	}
+	return grade, nil
+}
+
+// checkSpaced проверяет состояние интервального повторения задачи и задаёт
+// начальное. У задач с другим правилом состояние сбрасывается.
+func checkSpaced(task *Task) error {
+	if !IsSpaced(task.Repeat) {
+		task.Ease, task.Interval, task.Repetitions = 0, 0, 0
+		return nil
+	}
+	if task.Ease == 0 {
+		task.Ease = initialEase
+	}
+	if task.Ease < minEase || task.Interval < 0 || task.Repetitions < 0 {
+		return ErrInvalidSpacedState
+	}
+	return nil
+}
+
+// Review пересчитывает состояние задачи по оценке grade по алгоритму SM-2
+// и возвращает следующую дату: интервал отсчитывается от дня выполнения now.
+// Если следующая дата выходит за условия окончания серии, возвращается ErrSeriesEnded.
+func (t *Task) Review(now time.Time, grade int) (string, error) {
+	ease := t.Ease
+	if ease == 0 {
+		ease = initialEase
+	}
+
+	if grade < passingGrade {
+		t.Repetitions, t.Interval = 0, 1
+	} else {
+		switch t.Repetitions {
+		case 0:
+			t.Interval = 1
+		case 1:
+			t.Interval = 6
+		default:
+			t.Interval = int(math.Round(float64(t.Interval) * ease))
+		}
+		t.Repetitions++
+	}
+
+	miss := float64(maxGrade - grade)
+	ease += 0.1 - miss*(0.08+miss*0.02)
+	// округление убирает хвосты двоичной арифметики: 2.5 - 0.14 = 2.36
+	t.Ease = max(math.Round(ease*100)/100, minEase)
+
+	next := dateOf(now, time.UTC).AddDate(0, 0, t.Interval)
+	series := t.Series()
+	for i := 0; i < len(series.Exdates) && series.excluded(next); i++ {
+		next = next.AddDate(0, 0, 1)
+	}
+	if t.Remaining == 1 || series.ended(next) {
+		return "", ErrSeriesEnded
+	}
+	return next.Format(Layout), nil
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"strings"
+)
+
+// maxSubtasks — наибольшее число подзадач у задачи.
+const maxSubtasks = 100
+
+var (
+	ErrSubtaskTitleIsEmpty error = errors.New("subtask title is empty")
+	ErrManySubtasks        error = errors.New("subtasks are more than 100")
+	ErrOpenSubtasks        error = errors.New("task has open subtasks")
+)
+
+// Subtask — пункт чек-листа задачи.
+type Subtask struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Done  bool   `json:"done"`
+}
+
+// Progress возвращает долю выполненных подзадач в процентах, округлённую вниз,
+// или nil, если подзадач нет.
+func Progress(subtasks []Subtask) *int {
+	if len(subtasks) == 0 {
+		return nil
+	}
+	done := 0
+	for _, s := range subtasks {
+		if s.Done {
+			done++
+		}
+	}
+	progress := done * 100 / len(subtasks)
+	return &progress
+}
+
+// HasOpenSubtasks сообщает, есть ли у задачи невыполненные подзадачи.
+func (t *Task) HasOpenSubtasks() bool {
+	for _, s := range t.Subtasks {
+		if !s.Done {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSubtasks проверяет чек-лист задачи и убирает пробелы по краям заголовков.
+func checkSubtasks(task *Task) error {
+	if len(task.Subtasks) > maxSubtasks {
+		return ErrManySubtasks
+	}
+	for i := range task.Subtasks {
+		task.Subtasks[i].Title = strings.TrimSpace(task.Subtasks[i].Title)
+		if task.Subtasks[i].Title == "" {
+			return ErrSubtaskTitleIsEmpty
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTagLength — наибольшая длина метки в символах.
+const maxTagLength = 64
+
+var (
+	ErrInvalidTag   error = errors.New("tag must be from 1 to 64 characters without spaces and commas")
+	ErrTagExists    error = errors.New("tag already exists")
+	ErrInvalidMatch error = errors.New("match must be all or any")
+)
+
+// TaskFilter — условия отбора списка задач.
+type TaskFilter struct {
+	// Search — дата или строка, которую ищут в заголовке и комментарии.
+	Search string
+	// ListID — список задач; пустой — все списки.
+	ListID string
+	// Tags — метки задачи; при AnyTag достаточно одной из них, иначе нужны все.
+	Tags   []string
+	AnyTag bool
+	// Unblocked — только задачи, у которых нет открытых блокирующих задач.
+	Unblocked bool
+}
+
+// NormalizeTag приводит метку к виду, в котором она хранится:
+// без ведущего "#" и в нижнем регистре.
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" || utf8.RuneCountInString(tag) > maxTagLength || strings.ContainsAny(tag, " \t,#") {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+// normalizeTags приводит метки задачи к хранимому виду, сортирует их и убирает повторы.
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
+
+// ParseTaskFilter разбирает параметры списка задач: search, в котором слова
+// "#метка" задают метки, tags — метки через запятую, и match — "all"
+// (по умолчанию, нужны все метки) или "any" (достаточно одной).
+func ParseTaskFilter(search, tags, match string) (TaskFilter, error) {
+	var filter TaskFilter
+	switch match {
+	case "", "all":
+	case "any":
+		filter.AnyTag = true
+	default:
+		return TaskFilter{}, ErrInvalidMatch
+	}
+
+	var words, names []string
+	for _, word := range strings.Fields(search) {
+		if strings.HasPrefix(word, "#") {
+			names = append(names, word)
+			continue
+		}
+		words = append(words, word)
+	}
+	if len(names) == 0 {
+		// без меток строка поиска остаётся как есть
+		filter.Search = search
+	} else {
+		filter.Search = strings.Join(words, " ")
+	}
+	if tags != "" {
+		names = append(names, strings.Split(tags, ",")...)
+	}
+
+	var err error
+	if filter.Tags, err = normalizeTags(names); err != nil {
+		return TaskFilter{}, err
+	}
+	return filter, nil
+}
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrUndoExpired error = errors.New("undo window has expired")
+
+// Undoable проверяет, что выполнение сделано не раньше чем window назад.
+func (c *Completion) Undoable(now time.Time, window time.Duration) error {
+	completedAt, err := time.Parse(time.RFC3339, c.CompletedAt)
+	if err != nil {
+		return fmt.Errorf("time.Parse: completed_at is in invalid format: %w", err)
+	}
+	if now.Sub(completedAt) > window {
+		return ErrUndoExpired
+	}
+	return nil
+}
@@ -0,0 +1,404 @@
+package api
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RuleError — ошибка в правиле повторения или другом поле задачи в виде,
+// удобном для клиента: код, поле, ошибочная часть правила с позицией
+// (номер символа с 1) и исправленный вариант, если его можно предложить.
+type RuleError struct {
+	Code       string `json:"code"`
+	Field      string `json:"field,omitempty"`
+	Token      string `json:"token,omitempty"`
+	Position   int    `json:"position,omitempty"`
+	Suggestion string `json:"suggestion,omitempty"`
+	Err        error  `json:"-"`
+}
+
+func (e *RuleError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// ruleErrorCodes сопоставляет ошибкам код и поле задачи, к которому они относятся.
+var ruleErrorCodes = []struct {
+	err   error
+	code  string
+	field string
+}{
+	{ErrInvalidJSONFormat, "invalid_json", ""},
+	{ErrTitleIsEmpty, "empty_title", "title"},
+	{ErrInvalidDate, "invalid_date", "date"},
+	{ErrInvalidTime, "invalid_time", "time"},
+	{ErrInvalidDuration, "invalid_duration", "duration"},
+	{ErrInvalidPriority, "invalid_priority", "priority"},
+	{ErrInvalidTag, "invalid_tag", "tags"},
+	{ErrTagExists, "tag_exists", "name"},
+	{ErrInvalidMatch, "invalid_match", "match"},
+	{ErrInvalidListName, "invalid_list_name", "name"},
+	{ErrListExists, "list_exists", "name"},
+	{ErrListNotFound, "list_not_found", "list_id"},
+	{ErrDefaultList, "default_list", "id"},
+	{ErrSubtaskTitleIsEmpty, "empty_subtask_title", "subtasks"},
+	{ErrManySubtasks, "too_many_subtasks", "subtasks"},
+	{ErrOpenSubtasks, "open_subtasks", "subtasks"},
+	{ErrSelfDependency, "self_dependency", "blocker"},
+	{ErrDependencyCycle, "dependency_cycle", "blocker"},
+	{ErrBlockerNotFound, "blocker_not_found", "blocker"},
+	{ErrDependencyNotFound, "dependency_not_found", "blocker"},
+	{ErrInvalidVersion, "invalid_version", "version"},
+	{ErrVersionConflict, "version_conflict", "version"},
+	{ErrInvalidSort, "invalid_sort", "sort"},
+	{ErrInvalidOrder, "invalid_order", "order"},
+	{ErrInvalidTimezone, "invalid_timezone", "tz"},
+	{ErrInvalidUntil, "invalid_until", "until"},
+	{ErrInvalidRemaining, "invalid_remaining", "remaining"},
+	{ErrSeriesEnded, "series_ended", "until"},
+	{ErrEndWithoutRepeat, "end_without_repeat", "repeat"},
+	{ErrInvalidExdate, "invalid_exdate", "exdates"},
+	{ErrExdatesWithoutRepeat, "exdates_without_repeat", "repeat"},
+	{ErrExdateNotFound, "exdate_not_found", "exdates"},
+	{ErrInvalidPhrase, "invalid_phrase", "repeat_text"},
+	{ErrRepeatAndRepeatText, "repeat_conflict", "repeat_text"},
+	{ErrInvalidRepeatParameter, "invalid_argument", "repeat"},
+	{ErrUnknownFormat, "unknown_format", "repeat"},
+	{ErrManyDays, "too_many_days", "repeat"},
+	{ErrManyWeeks, "weekday_out_of_range", "repeat"},
+	{ErrManyMonths, "month_out_of_range", "repeat"},
+	{ErrInvalidFormatInDay, "invalid_day_interval", "repeat"},
+	{ErrInvalidFormatInMonth, "invalid_month_day", "repeat"},
+	{ErrInvalidFormatInNth, "invalid_nth_weekday", "repeat"},
+	{ErrInvalidFormatInBusinessDay, "invalid_business_days", "repeat"},
+	{ErrInvalidFormatSpaced, "invalid_spaced", "repeat"},
+	{ErrInvalidGrade, "invalid_grade", "grade"},
+	{ErrInvalidSpacedState, "invalid_spaced_state", "ease"},
+	{ErrInvalidWeekInterval, "invalid_week_interval", "repeat"},
+	{ErrInvalidYearInterval, "invalid_year_interval", "repeat"},
+	{ErrInvalidRRule, "invalid_rrule", "repeat"},
+	{ErrInvalidCron, "invalid_cron", "repeat"},
+	{ErrUnsupportedRRule, "unsupported_rrule", "repeat"},
+	{ErrNoOccurrence, "no_occurrence", "repeat"},
+	{ErrNotConvertible, "not_convertible", ""},
+}
+
+// ruleToken — часть правила и её позиция (номер символа с 1).
+type ruleToken struct {
+	text string
+	pos  int
+}
+
+// ValidateRepeat проверяет правило повторения. Для неверного правила
+// возвращается *RuleError с ошибочной частью и исправленным правилом.
+func ValidateRepeat(repeat string) error {
+	if err := checkRepeat(repeat); err != nil {
+		return NewRuleError("repeat", repeat, err)
+	}
+	return nil
+}
+
+// checkRepeat вычисляет следующую дату от сегодняшнего дня, чтобы правило
+// проверялось тем же кодом, что и при выполнении задачи.
+func checkRepeat(repeat string) error {
+	now := time.Now()
+	_, err := NextDate(now, now.Format(Layout), repeat)
+	if errors.Is(err, ErrSeriesEnded) {
+		// правило верное, просто UNTIL уже прошёл
+		return nil
+	}
+	return err
+}
+
+// NewRuleError превращает err в *RuleError. Поле field определяется по ошибке,
+// если не задано. Если ошибка относится к правилу повторения, в value
+// передаётся правило: в нём ищется ошибочная часть.
+func NewRuleError(field, value string, err error) *RuleError {
+	var ruleErr *RuleError
+	if errors.As(err, &ruleErr) {
+		return ruleErr
+	}
+
+	ruleErr = &RuleError{Code: "invalid_request", Field: field, Err: err}
+	for _, c := range ruleErrorCodes {
+		if errors.Is(err, c.err) {
+			ruleErr.Code = c.code
+			if ruleErr.Field == "" {
+				ruleErr.Field = c.field
+			}
+			break
+		}
+	}
+
+	if ruleErr.Field == "repeat" && value != "" {
+		token, suggestion := locateRuleError(value, err)
+		ruleErr.Token, ruleErr.Position = token.text, token.pos
+		if suggestion != value && checkRepeat(suggestion) == nil {
+			ruleErr.Suggestion = suggestion
+		}
+	}
+	return ruleErr
+}
+
+// splitRule делит s на части по sep; offset — позиция s в исходном правиле.
+func splitRule(s string, sep string, offset int) []ruleToken {
+	var tokens []ruleToken
+	pos := offset
+	for _, part := range strings.Split(s, sep) {
+		tokens = append(tokens, ruleToken{text: part, pos: pos + 1})
+		pos += len([]rune(part)) + len(sep)
+	}
+	return tokens
+}
+
+// locateRuleError находит часть правила, из-за которой оно неверно,
+// и предлагает исправленное правило.
+func locateRuleError(repeat string, err error) (ruleToken, string) {
+	if space, ok := extraSpace(repeat); ok {
+		// исправление ищется уже в правиле без лишних пробелов
+		trimmed := strings.Join(strings.Fields(repeat), " ")
+		if err := checkRepeat(trimmed); err != nil && trimmed != "" {
+			_, suggestion := locateRuleError(trimmed, err)
+			return space, suggestion
+		}
+		return space, trimmed
+	}
+	if IsRRule(repeat) {
+		return locateRRuleError(repeat, err)
+	}
+	if IsCron(repeat) {
+		return locateCronError(repeat), ""
+	}
+
+	base, shift, shifted := cutShift(repeat)
+	tokens := splitRule(base, " ", 0)
+	name := tokens[0].text
+	known := slices.Contains([]string{day, year, week, month, nthWeekday, businessDay, spaced}, name)
+
+	_, _, doubled := cutShift(base)
+	if shifted && (doubled || known && !slices.Contains(shiftable, name)) {
+		return ruleToken{text: shift, pos: len([]rune(base)) + 2}, base
+	}
+
+	token, suggestion := locateShortError(tokens)
+	if !known {
+		// возможно, вместо правила прислали фразу: "каждый вторник"
+		if phrase, err := ParsePhrase(repeat, time.Now()); err == nil {
+			return token, phrase
+		}
+		if lower := strings.ToLower(repeat); lower != repeat {
+			return token, lower
+		}
+		return token, ""
+	}
+	if shifted && suggestion != "" {
+		suggestion += " " + shift
+	}
+	return token, suggestion
+}
+
+// extraSpace находит первый лишний пробел правила: в начале, в конце
+// или повторённый между частями.
+func extraSpace(repeat string) (ruleToken, bool) {
+	runes := []rune(repeat)
+	for i := 0; i < len(runes); i++ {
+		if !unicode.IsSpace(runes[i]) {
+			continue
+		}
+		j := i
+		for j < len(runes) && unicode.IsSpace(runes[j]) {
+			j++
+		}
+		if i == 0 || j == len(runes) || j-i > 1 || runes[i] != ' ' {
+			return ruleToken{text: string(runes[i:j]), pos: i + 1}, true
+		}
+		i = j - 1
+	}
+	return ruleToken{}, false
+}
+
+// locateShortError проверяет части правила в формате планировщика по тем же
+// ограничениям, что и NextDate.
+func locateShortError(tokens []ruleToken) (ruleToken, string) {
+	name := tokens[0].text
+	isNumber := func(lo, hi int, nonZero bool) func(string) bool {
+		return func(s string) bool {
+			n, err := strconv.Atoi(s)
+			return err == nil && n >= lo && n <= hi && (!nonZero || n != 0)
+		}
+	}
+
+	switch name {
+	case day, businessDay:
+		if len(tokens) < 2 {
+			return tokens[0], name + " 1"
+		}
+		if len(tokens) > 2 {
+			return tokens[2], name + " " + tokens[1].text
+		}
+		n, err := strconv.Atoi(tokens[1].text)
+		switch {
+		case err != nil || n < 1:
+			return tokens[1], name + " 1"
+		case n > 400:
+			return tokens[1], name + " 400"
+		}
+	case spaced:
+		if len(tokens) > 1 {
+			return tokens[1], spaced
+		}
+	case year:
+		if len(tokens) > 2 {
+			return tokens[2], year + " " + tokens[1].text
+		}
+		if len(tokens) == 2 && !isNumber(1, maxYearInterval, false)(tokens[1].text) {
+			return tokens[1], year
+		}
+	case week:
+		if len(tokens) < 2 {
+			return tokens[0], week + " 1"
+		}
+		if len(tokens) > 3 {
+			return tokens[3], week + " " + tokens[1].text + " " + tokens[2].text
+		}
+		interval := ""
+		if len(tokens) == 3 {
+			interval = " " + tokens[2].text
+		}
+		if bad, rest, ok := checkList(tokens[1], isNumber(1, 7, false)); !ok {
+			return bad, week + " " + orDefault(rest, "1") + interval
+		}
+		if len(tokens) == 3 && !isNumber(1, maxWeekInterval, false)(tokens[2].text) {
+			return tokens[2], week + " " + tokens[1].text
+		}
+	case month:
+		if len(tokens) < 2 {
+			return tokens[0], month + " 1"
+		}
+		if len(tokens) > 3 {
+			return tokens[3], month + " " + tokens[1].text + " " + tokens[2].text
+		}
+		months := ""
+		if len(tokens) == 3 {
+			months = " " + tokens[2].text
+		}
+		if bad, rest, ok := checkList(tokens[1], isNumber(-2, 31, true)); !ok {
+			return bad, month + " " + orDefault(rest, "1") + months
+		}
+		if len(tokens) == 3 {
+			if bad, rest, ok := checkList(tokens[2], isNumber(1, 12, false)); !ok {
+				return bad, strings.TrimSpace(month + " " + tokens[1].text + " " + rest)
+			}
+		}
+	case nthWeekday:
+		if len(tokens) < 2 {
+			return tokens[0], nthWeekday + " 1:1"
+		}
+		if len(tokens) > 3 {
+			return tokens[3], nthWeekday + " " + tokens[1].text + " " + tokens[2].text
+		}
+		months := ""
+		if len(tokens) == 3 {
+			months = " " + tokens[2].text
+		}
+		validItem := func(s string) bool {
+			ord, wd, ok := strings.Cut(s, ":")
+			return ok && (ord == "-1" || isNumber(1, 4, false)(ord)) && isNumber(1, 7, false)(wd)
+		}
+		if bad, rest, ok := checkList(tokens[1], validItem); !ok {
+			return bad, nthWeekday + " " + orDefault(rest, "1:1") + months
+		}
+		if len(tokens) == 3 {
+			if bad, rest, ok := checkList(tokens[2], isNumber(1, 12, false)); !ok {
+				return bad, strings.TrimSpace(nthWeekday + " " + tokens[1].text + " " + rest)
+			}
+		}
+	default:
+		return tokens[0], ""
+	}
+	return ruleToken{}, ""
+}
+
+// checkList ищет первый неверный элемент списка через запятую
+// и возвращает его вместе со списком из одних верных элементов.
+func checkList(list ruleToken, valid func(string) bool) (ruleToken, string, bool) {
+	var bad ruleToken
+	var rest []string
+	for _, item := range splitRule(list.text, ",", list.pos-1) {
+		if valid(item.text) {
+			rest = append(rest, item.text)
+		} else if bad.pos == 0 {
+			bad = item
+		}
+	}
+	return bad, strings.Join(rest, ","), bad.pos == 0
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// locateCronError находит первое поле cron-выражения, которое не разбирается.
+func locateCronError(repeat string) ruleToken {
+	pos := 0
+	for i, field := range strings.Fields(repeat) {
+		pos += strings.Index(repeat[pos:], field)
+		if _, err := cronFields[i].parse(field); err != nil {
+			return ruleToken{text: field, pos: len([]rune(repeat[:pos])) + 1}
+		}
+		pos += len(field)
+	}
+	return ruleToken{}
+}
+
+// locateRRuleError находит часть RRULE, которую называет ошибка parseRRule,
+// и предлагает правило без неё.
+func locateRRuleError(repeat string, err error) (ruleToken, string) {
+	body, offset := repeat, 0
+	if strings.HasPrefix(strings.ToUpper(repeat), rrulePrefix) {
+		body, offset = repeat[len(rrulePrefix):], len(rrulePrefix)
+	}
+
+	var sentinel error
+	for _, e := range []error{ErrInvalidRRule, ErrUnsupportedRRule} {
+		if errors.Is(err, e) {
+			sentinel = e
+		}
+	}
+	if sentinel == nil {
+		return ruleToken{}, ""
+	}
+	detail := strings.ToUpper(err.Error())
+	detail = detail[strings.Index(detail, strings.ToUpper(sentinel.Error()))+len(sentinel.Error()):]
+
+	if strings.Contains(detail, "FREQ IS REQUIRED") {
+		return ruleToken{}, "FREQ=DAILY;" + body
+	}
+
+	parts := splitRule(body, ";", offset)
+	for i, part := range parts {
+		name, _, _ := strings.Cut(part.text, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" || !strings.Contains(detail, name) {
+			continue
+		}
+		var rest []string
+		for j, p := range parts {
+			if j != i {
+				rest = append(rest, p.text)
+			}
+		}
+		return part, strings.Join(rest, ";")
+	}
+	return ruleToken{}, ""
+}
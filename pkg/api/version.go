@@ -0,0 +1,45 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// FirstVersion — версия новой задачи.
+const FirstVersion = 1
+
+var (
+	ErrInvalidVersion  error = errors.New("version must be a positive number")
+	ErrVersionConflict error = errors.New("task was changed by someone else")
+)
+
+// TaskUpdate — тело PUT /api/task: задача и версия, которую видел клиент.
+// Без версии задача записывается без проверки.
+type TaskUpdate struct {
+	Task
+	Version *int `json:"version"`
+}
+
+// ETag возвращает значение заголовка ETag для версии задачи.
+func ETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// ParseIfMatch разбирает заголовок If-Match: "3" или W/"3". "*" и пустой
+// заголовок означают, что версия не проверяется, и возвращают 0.
+func ParseIfMatch(header string) (int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	tag := strings.TrimPrefix(header, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, ErrInvalidVersion
+	}
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || version < 1 {
+		return 0, ErrInvalidVersion
+	}
+	return version, nil
+}
@@ -0,0 +1,165 @@
+package calendar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const layout = "20060102"
+
+var ErrInvalidDay error = errors.New("holiday date is in invalid format")
+
+// C — производственный календарь, по которому считаются рабочие дни.
+var C = New()
+
+// Day — запись календаря: праздник или выходной, объявленный рабочим днём.
+type Day struct {
+	Date    string `json:"date"`
+	Title   string `json:"title"`
+	Workday bool   `json:"workday"`
+}
+
+type Calendar struct {
+	mu   sync.RWMutex
+	days map[string]Day
+}
+
+func New() *Calendar {
+	return &Calendar{days: make(map[string]Day)}
+}
+
+// Set добавляет или заменяет запись календаря.
+func (c *Calendar) Set(day Day) error {
+	if _, err := time.Parse(layout, day.Date); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDay, day.Date)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.days[day.Date] = day
+	return nil
+}
+
+func (c *Calendar) Delete(date string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.days, date)
+}
+
+// IsWorkday сообщает, рабочий ли день t: будни, кроме праздников,
+// и выходные, перенесённые на рабочие.
+func (c *Calendar) IsWorkday(t time.Time) bool {
+	c.mu.RLock()
+	day, ok := c.days[t.Format(layout)]
+	c.mu.RUnlock()
+	if ok {
+		return day.Workday
+	}
+	return isWeekday(t)
+}
+
+// NextWorkday возвращает t, если это рабочий день, иначе ближайший следующий рабочий день.
+func (c *Calendar) NextWorkday(t time.Time) time.Time {
+	for !c.IsWorkday(t) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// PrevWorkday возвращает t, если это рабочий день, иначе ближайший предыдущий рабочий день.
+func (c *Calendar) PrevWorkday(t time.Time) time.Time {
+	for !c.IsWorkday(t) {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// AddWorkdays возвращает дату через n рабочих дней после t.
+func (c *Calendar) AddWorkdays(t time.Time, n int) time.Time {
+	for n > 0 {
+		t = t.AddDate(0, 0, 1)
+		if c.IsWorkday(t) {
+			n--
+		}
+	}
+	return t
+}
+
+// CountWorkdays возвращает число рабочих дней в полуинтервале (from, to].
+// Будни полных недель считаются арифметически, затем учитываются записи календаря.
+func (c *Calendar) CountWorkdays(from, to time.Time) int {
+	if !to.After(from) {
+		return 0
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+
+	weeks := int(to.Sub(from).Hours()/24) / 7
+	count := weeks * 5
+	for t := from.AddDate(0, 0, weeks*7+1); !t.After(to); t = t.AddDate(0, 0, 1) {
+		if isWeekday(t) {
+			count++
+		}
+	}
+
+	fromStr, toStr := from.Format(layout), to.Format(layout)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for date, day := range c.days {
+		if date <= fromStr || date > toStr {
+			continue
+		}
+		t, err := time.Parse(layout, date)
+		if err != nil {
+			continue
+		}
+		switch {
+		case day.Workday && !isWeekday(t):
+			count++
+		case !day.Workday && isWeekday(t):
+			count--
+		}
+	}
+	return count
+}
+
+func isWeekday(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// LoadFile читает календарь из текстового файла. Каждая строка — дата
+// в формате YYYYMMDD и необязательное название через пробел. Дата с префиксом "+"
+// означает рабочий выходной, строки с "#" считаются комментариями.
+//
+//	20250101 Новый год
+//	+20250503 Перенос с 8 мая
+func (c *Calendar) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("os.Open: cannot open holidays file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		date, title, _ := strings.Cut(text, " ")
+		day := Day{Title: strings.TrimSpace(title)}
+		day.Date, day.Workday = strings.CutPrefix(date, "+")
+		if err := c.Set(day); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("scanner.Err: cannot read holidays file: %w", err)
+	}
+
+	return nil
+}
@@ -2,11 +2,15 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/NarthurN/TODO-API-web/internal/config"
 	"github.com/NarthurN/TODO-API-web/pkg/api"
+	"github.com/NarthurN/TODO-API-web/pkg/calendar"
 	"github.com/NarthurN/TODO-API-web/pkg/loger"
 	_ "modernc.org/sqlite"
 )
@@ -15,6 +19,72 @@ type TaskStorage struct {
 	SqlStorage *sql.DB
 }
 
+// taskColumns — колонки scheduler в порядке полей, которые читает scanTask.
+const taskColumns = "id, date, title, comment, repeat, until, remaining, time, duration, from_completion, exdates, ease, interval, repetitions, deleted_at, priority, list_id, created_at, updated_at, version, anchor"
+
+type migration struct {
+	column     string
+	definition string
+}
+
+// migrations — колонки, добавленные в scheduler после первой версии схемы.
+// При запуске недостающие колонки добавляются в существующую таблицу.
+var migrations = []migration{
+	{"until", `CHAR(8) NOT NULL DEFAULT ""`},
+	{"remaining", `INTEGER NOT NULL DEFAULT 0`},
+	{"time", `CHAR(5) NOT NULL DEFAULT ""`},
+	{"duration", `INTEGER NOT NULL DEFAULT 0`},
+	{"from_completion", `INTEGER NOT NULL DEFAULT 0`},
+	{"exdates", `TEXT NOT NULL DEFAULT ""`},
+	{"ease", `REAL NOT NULL DEFAULT 0`},
+	{"interval", `INTEGER NOT NULL DEFAULT 0`},
+	{"repetitions", `INTEGER NOT NULL DEFAULT 0`},
+	// archived — выполненная одноразовая задача: она остаётся в таблице для истории,
+	// но не возвращается вместе с остальными задачами.
+	{"archived", `INTEGER NOT NULL DEFAULT 0`},
+	// deleted_at — момент удаления в формате RFC 3339 (UTC); пустая строка — задача не в корзине.
+	{"deleted_at", `VARCHAR(32) NOT NULL DEFAULT ""`},
+	// priority — от 0 (не задан) до 3 (высокий)
+	{"priority", `INTEGER NOT NULL DEFAULT 0`},
+	// list_id — список задачи; задачи, созданные до появления списков, попадают во «Входящие»
+	{"list_id", `INTEGER NOT NULL DEFAULT 1`},
+	// created_at и updated_at — моменты создания и последнего изменения (RFC 3339, UTC);
+	// у задач, созданных до появления колонок, пустые
+	{"created_at", `VARCHAR(32) NOT NULL DEFAULT ""`},
+	{"updated_at", `VARCHAR(32) NOT NULL DEFAULT ""`},
+	// version растёт при каждом изменении задачи
+	{"version", `INTEGER NOT NULL DEFAULT 1`},
+	// anchor — дата текущего повторения до переноса на рабочий день; пустая — не переносилась
+	{"anchor", `CHAR(8) NOT NULL DEFAULT ""`},
+}
+
+// touch — часть SET, которая отмечает изменение задачи: увеличивает версию
+// и обновляет updated_at.
+const touch = "version = version + 1, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')"
+
+// completionMigrations — колонки, добавленные в completions: состояние задачи
+// до выполнения, по которому выполнение отменяется.
+var completionMigrations = []migration{
+	{"remaining", `INTEGER NOT NULL DEFAULT 0`},
+	{"ease", `REAL NOT NULL DEFAULT 0`},
+	{"interval", `INTEGER NOT NULL DEFAULT 0`},
+	{"repetitions", `INTEGER NOT NULL DEFAULT 0`},
+	// archived — выполнение отправило задачу в архив
+	{"archived", `INTEGER NOT NULL DEFAULT 0`},
+	// done_subtasks — JSON-массив id выполненных подзадач
+	{"done_subtasks", `TEXT NOT NULL DEFAULT '[]'`},
+	{"anchor", `CHAR(8) NOT NULL DEFAULT ""`},
+}
+
+// indexes создаются после миграций, так как могут ссылаться на добавленные колонки.
+var indexes = []string{
+	"CREATE INDEX IF NOT EXISTS scheduler_date_time ON scheduler (date, time)",
+	"CREATE INDEX IF NOT EXISTS scheduler_priority ON scheduler (priority, date, time)",
+	"CREATE INDEX IF NOT EXISTS scheduler_title ON scheduler (title)",
+	"CREATE INDEX IF NOT EXISTS scheduler_list_date ON scheduler (list_id, date, time)",
+	"CREATE INDEX IF NOT EXISTS completions_task_id ON completions (task_id)",
+}
+
 func New() (*TaskStorage, error) {
 	dbFile := config.Cfg.TODO_DBFILE
 	db, err := sql.Open("sqlite", dbFile)
@@ -37,6 +107,10 @@ func New() (*TaskStorage, error) {
 		return nil, fmt.Errorf("createTable: cannot create table: %w", err)
 	}
 
+	if err := migrate(storage); err != nil {
+		return nil, fmt.Errorf("migrate: cannot migrate table: %w", err)
+	}
+
 	return storage, nil
 }
 
@@ -50,6 +124,48 @@ func createTable(storage *TaskStorage) error {
 			repeat VARCHAR(128) NOT NULL DEFAULT ""
 		);
 		CREATE INDEX IF NOT EXISTS scheduler_date ON scheduler (date);
+		CREATE TABLE IF NOT EXISTS holidays (
+			date CHAR(8) PRIMARY KEY,
+			title VARCHAR(256) NOT NULL DEFAULT "",
+			workday INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE TABLE IF NOT EXISTS completions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			title VARCHAR(256) NOT NULL DEFAULT "",
+			date CHAR(8) NOT NULL DEFAULT "",
+			completed_at VARCHAR(32) NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS completions_completed_at ON completions (completed_at);
+		CREATE TABLE IF NOT EXISTS lists (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name VARCHAR(128) NOT NULL UNIQUE
+		);
+		INSERT OR IGNORE INTO lists (id, name) VALUES (1, "Входящие");
+		CREATE TABLE IF NOT EXISTS subtasks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			title VARCHAR(256) NOT NULL DEFAULT "",
+			done INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE INDEX IF NOT EXISTS subtasks_task_id ON subtasks (task_id, position);
+		CREATE TABLE IF NOT EXISTS dependencies (
+			task_id INTEGER NOT NULL,
+			blocker_id INTEGER NOT NULL,
+			PRIMARY KEY (task_id, blocker_id)
+		);
+		CREATE INDEX IF NOT EXISTS dependencies_blocker_id ON dependencies (blocker_id);
+		CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name VARCHAR(64) NOT NULL UNIQUE
+		);
+		CREATE TABLE IF NOT EXISTS task_tags (
+			task_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			PRIMARY KEY (task_id, tag_id)
+		);
+		CREATE INDEX IF NOT EXISTS task_tags_tag_id ON task_tags (tag_id);
 	`)
 	if err != nil {
 		return fmt.Errorf("storage.SqlStorage.Exec: failed to create scheduler table: %w", err)
@@ -58,6 +174,86 @@ func createTable(storage *TaskStorage) error {
 	return nil
 }
 
+func migrate(storage *TaskStorage) error {
+	if err := addColumns(storage, "scheduler", migrations); err != nil {
+		return err
+	}
+	if err := addColumns(storage, "completions", completionMigrations); err != nil {
+		return err
+	}
+
+	// моменты выполнения раньше записывались со смещением часового пояса
+	// пользователя; в UTC они сравниваются как строки
+	_, err := storage.SqlStorage.Exec(`UPDATE completions
+		SET completed_at = strftime('%Y-%m-%dT%H:%M:%SZ', completed_at)
+		WHERE completed_at NOT LIKE '%Z' AND strftime('%s', completed_at) IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("storage.SqlStorage.Exec: cannot convert completions to UTC: %w", err)
+	}
+
+	for _, index := range indexes {
+		if _, err := storage.SqlStorage.Exec(index); err != nil {
+			return fmt.Errorf("storage.SqlStorage.Exec: cannot create index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// addColumns добавляет в таблицу table недостающие колонки.
+func addColumns(storage *TaskStorage, table string, columns []migration) error {
+	rows, err := storage.SqlStorage.Query("SELECT name FROM pragma_table_info(:table)", sql.Named("table", table))
+	if err != nil {
+		return fmt.Errorf("storage.SqlStorage.Query: cannot read %s columns: %w", table, err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("rows.Scan: cannot do Scan: %w", err)
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows.Err: err in rows: %w", err)
+	}
+
+	for _, m := range columns {
+		if existing[m.column] {
+			continue
+		}
+		_, err := storage.SqlStorage.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, m.column, m.definition))
+		if err != nil {
+			return fmt.Errorf("storage.SqlStorage.Exec: cannot add column %s: %w", m.column, err)
+		}
+		loger.L.Info("column added to "+table, "column", m.column)
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTask читает задачу; исключённые даты хранятся в одной колонке через запятую.
+func scanTask(row scanner, task *api.Task) error {
+	var exdates string
+	err := row.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Until, &task.Remaining,
+		&task.Time, &task.Duration, &task.FromCompletion, &exdates, &task.Ease, &task.Interval, &task.Repetitions,
+		&task.DeletedAt, &task.Priority, &task.ListID, &task.CreatedAt, &task.UpdatedAt, &task.Version, &task.Anchor)
+	if err != nil {
+		return err
+	}
+	task.Exdates = nil
+	if exdates != "" {
+		task.Exdates = strings.Split(exdates, ",")
+	}
+	return nil
+}
+
 func (t *TaskStorage) Close() error {
 	err := t.SqlStorage.Close()
 	if err != nil {
@@ -67,52 +263,140 @@ func (t *TaskStorage) Close() error {
 }
 
 func (t *TaskStorage) AddTask(task api.Task) (int64, error) {
-	res, err := t.SqlStorage.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (:date, :title, :comment, :repeat)`,
+	tx, err := t.SqlStorage.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("t.SqlStorage.Begin: cannot start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO scheduler (date, title, comment, repeat, until, remaining, time, duration,
+			from_completion, exdates, ease, interval, repetitions, priority, list_id, created_at, updated_at, version, anchor)
+		VALUES (:date, :title, :comment, :repeat, :until, :remaining, :time, :duration, :from_completion, :exdates,
+			:ease, :interval, :repetitions, :priority, COALESCE(NULLIF(:list_id, ''), `+api.DefaultListID+`),
+			:created_at, :updated_at, max(:version, `+strconv.Itoa(api.FirstVersion)+`), :anchor)`,
 		sql.Named("date", task.Date),
 		sql.Named("title", task.Title),
 		sql.Named("comment", task.Comment),
-		sql.Named("repeat", task.Repeat))
+		sql.Named("repeat", task.Repeat),
+		sql.Named("until", task.Until),
+		sql.Named("remaining", task.Remaining),
+		sql.Named("time", task.Time),
+		sql.Named("duration", task.Duration),
+		sql.Named("from_completion", task.FromCompletion),
+		sql.Named("exdates", strings.Join(task.Exdates, ",")),
+		sql.Named("ease", task.Ease),
+		sql.Named("interval", task.Interval),
+		sql.Named("repetitions", task.Repetitions),
+		sql.Named("priority", task.Priority),
+		sql.Named("list_id", task.ListID),
+		sql.Named("created_at", task.CreatedAt),
+		sql.Named("updated_at", task.UpdatedAt),
+		sql.Named("version", task.Version),
+		sql.Named("anchor", task.Anchor))
+	if err != nil {
+		return 0, fmt.Errorf("tx.Exec: error by inserting task: %w", err)
+	}
+	id, err := res.LastInsertId()
 	if err != nil {
-		return 0, fmt.Errorf("t.SqlStorage.Exec: error by inserting task: %w", err)
+		return 0, fmt.Errorf("res.LastInsertId: %w", err)
 	}
 
-	return res.LastInsertId()
+	if err := setTags(tx, id, task.Tags); err != nil {
+		return 0, fmt.Errorf("setTags: %w", err)
+	}
+	if err := setSubtasks(tx, id, task.Subtasks); err != nil {
+		return 0, fmt.Errorf("setSubtasks: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("tx.Commit: cannot commit task: %w", err)
+	}
+	return id, nil
 }
 
-func (t *TaskStorage) GetTasks(limit int, rowSearch string) ([]api.Task, error) {
-	loger.L.Info("Зпрос search", "search", rowSearch)
+// orderColumns — выражения ORDER BY для полей сортировки: %[1]s заменяется
+// направлением. Равные задачи идут в порядке создания.
+var orderColumns = map[string]string{
+	api.SortDate:     "date %[1]s, time %[1]s, id %[1]s",
+	api.SortPriority: "priority %[1]s, date, time, id",
+	api.SortTitle:    "title %[1]s, id",
+	api.SortCreated:  "id %[1]s",
+}
+
+func orderBy(order api.TaskOrder) string {
+	columns, ok := orderColumns[order.Field]
+	if !ok {
+		columns = orderColumns[api.SortDate]
+	}
+	direction := "ASC"
+	if order.Desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf(columns, direction)
+}
+
+// blocking — условие, при котором blocker ещё блокирует зависимую задачу %[1]s:
+// одноразовая задача блокирует, пока не выполнена, а повторяющаяся — пока её
+// очередная дата не позже даты зависимой задачи.
+const blocking = `blocker.archived = 0 AND blocker.deleted_at = ''
+	AND (blocker.repeat = '' OR blocker.date <= %[1]s.date)`
+
+// unblockedFilter — у задачи нет открытых блокирующих задач.
+var unblockedFilter = fmt.Sprintf(`NOT EXISTS (
+	SELECT 1 FROM dependencies JOIN scheduler AS blocker ON blocker.id = dependencies.blocker_id
+	WHERE dependencies.task_id = scheduler.id AND %s)`, fmt.Sprintf(blocking, "scheduler"))
+
+// tagFilter — условие на метки задачи: :tags — JSON-массив меток,
+// :all_tags — число меток, если нужны все, или 0, если достаточно одной.
+const tagFilter = `id IN (
+	SELECT task_tags.task_id FROM task_tags JOIN tags ON tags.id = task_tags.tag_id
+	WHERE tags.name IN (SELECT value FROM json_each(:tags))
+	GROUP BY task_tags.task_id
+	HAVING :all_tags = 0 OR count(*) = :all_tags)`
+
+func (t *TaskStorage) GetTasks(limit int, filter api.TaskFilter, order api.TaskOrder) ([]api.Task, error) {
+	loger.L.Info("Зпрос search", "search", filter.Search, "tags", filter.Tags)
 	tasks := make([]api.Task, 0)
-	var rows *sql.Rows
-	search, ok := IsDate(rowSearch)
+	where := "archived = 0 AND deleted_at = '' AND (:list_id = '' OR list_id = :list_id)"
+	search, ok := IsDate(filter.Search)
 	if ok {
-		var err error
-		rows, err = t.SqlStorage.Query(`SELECT * FROM scheduler WHERE date = :search LIMIT :limit `,
-			sql.Named("search", search),
-			sql.Named("limit", limit))
-		if err != nil {
-			return nil, fmt.Errorf("t.SqlStorage.Query: cannot do SELECT: %w", err)
-		}
+		where += " AND date = :search"
 	} else {
-		var err error
-		rows, err = t.SqlStorage.Query(`
-			SELECT * FROM scheduler 
-			WHERE title LIKE '%' || :search || '%'
-			OR comment LIKE '%' || :search || '%'
-			ORDER BY date 
-			LIMIT :limit`,
-			sql.Named("search", search),
-			sql.Named("limit", limit))
-		if err != nil {
-			return nil, fmt.Errorf("t.SqlStorage.Query: cannot do SELECT: %w", err)
+		where += " AND (title LIKE '%' || :search || '%' OR comment LIKE '%' || :search || '%')"
+	}
+	tags, err := json.Marshal(filter.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: cannot encode tags: %w", err)
+	}
+	if filter.Unblocked {
+		where += " AND " + unblockedFilter
+	}
+	allTags := 0
+	if len(filter.Tags) > 0 {
+		where += " AND " + tagFilter
+		if !filter.AnyTag {
+			allTags = len(filter.Tags)
 		}
 	}
 
+	rows, err := t.SqlStorage.Query(`
+		SELECT `+taskColumns+` FROM scheduler
+		WHERE `+where+`
+		ORDER BY `+orderBy(order)+`
+		LIMIT :limit`,
+		sql.Named("search", search),
+		sql.Named("list_id", filter.ListID),
+		sql.Named("tags", string(tags)),
+		sql.Named("all_tags", allTags),
+		sql.Named("limit", limit))
+	if err != nil {
+		return nil, fmt.Errorf("t.SqlStorage.Query: cannot do SELECT: %w", err)
+	}
 	defer rows.Close()
 
 	for rows.Next() {
 		task := api.Task{}
 
-		err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+		err := scanTask(rows, &task)
 		if err != nil {
 			return nil, fmt.Errorf("rows.Scan: cannot do Scan: %w", err)
 		}
@@ -123,6 +407,12 @@ func (t *TaskStorage) GetTasks(limit int, rowSearch string) ([]api.Task, error)
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows.Err: err in rows: %w", err)
 	}
+	if err := t.loadTags(tasks); err != nil {
+		return nil, fmt.Errorf("t.loadTags: %w", err)
+	}
+	if err := t.loadDependencies(tasks); err != nil {
+		return nil, fmt.Errorf("t.loadDependencies: %w", err)
+	}
 	loger.L.Info("Получили tasks", "tasks", tasks)
 	return tasks, nil
 }
@@ -134,10 +424,10 @@ func (t *TaskStorage) GetTask(id string) (*api.Task, error) {
 	}
 
 	task := &api.Task{}
-	err := t.SqlStorage.QueryRow(
-		"SELECT id, date, title, comment, repeat FROM scheduler WHERE id = :id",
+	err := scanTask(t.SqlStorage.QueryRow(
+		"SELECT "+taskColumns+" FROM scheduler WHERE id = :id AND archived = 0 AND deleted_at = ''",
 		sql.Named("id", id),
-	).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	), task)
 	if err == sql.ErrNoRows {
 		loger.L.Error("no task found", "id", id)
 		return task, fmt.Errorf("no task with id %s", id)
@@ -146,47 +436,107 @@ func (t *TaskStorage) GetTask(id string) (*api.Task, error) {
 		loger.L.Error("failed to query task", "id", id, "error", err)
 		return task, fmt.Errorf("t.SqlStorage.QueryRow: failed to get task with id %s: %w", id, err)
 	}
+	tasks := []api.Task{*task}
+	if err := t.loadTags(tasks); err != nil {
+		return task, fmt.Errorf("t.loadTags: %w", err)
+	}
+	if err := t.loadDependencies(tasks); err != nil {
+		return task, fmt.Errorf("t.loadDependencies: %w", err)
+	}
+	task.Tags, task.BlockedBy, task.Blocks = tasks[0].Tags, tasks[0].BlockedBy, tasks[0].Blocks
+	if err := t.loadSubtasks(task); err != nil {
+		return task, fmt.Errorf("t.loadSubtasks: %w", err)
+	}
 
 	loger.L.Info("task retrieved", "id", id)
 	return task, nil
 }
 
+// UpdateTask записывает задачу и возвращает в task новые версию и момент
+// изменения. Если task.Version не 0, задача записывается только в этой версии,
+// иначе возвращается api.ErrVersionConflict; версия 0 означает запись без проверки.
 func (t *TaskStorage) UpdateTask(task *api.Task) error {
 	if task.ID == "" {
 		loger.L.Error("invalid task ID", "id", task.ID)
 		return fmt.Errorf("invalid task ID: %s", task.ID)
 	}
 
-	result, err := t.SqlStorage.Exec(`
+	tx, err := t.SqlStorage.Begin()
+	if err != nil {
+		return fmt.Errorf("t.SqlStorage.Begin: cannot start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
         UPDATE scheduler 
-        SET date = :date, title = :title, comment = :comment, repeat = :repeat 
-        WHERE id = :id`,
+        SET date = :date, title = :title, comment = :comment, repeat = :repeat,
+            until = :until, remaining = :remaining, time = :time, duration = :duration,
+            from_completion = :from_completion, exdates = :exdates,
+            ease = :ease, interval = :interval, repetitions = :repetitions, priority = :priority,
+            list_id = COALESCE(NULLIF(:list_id, ''), list_id),
+            anchor = CASE WHEN :anchor = '' AND date = :date AND repeat = :repeat THEN anchor ELSE :anchor END,
+            `+touch+`
+        WHERE id = :id AND archived = 0 AND deleted_at = '' AND (:version = 0 OR version = :version)
+        RETURNING version, updated_at`,
 		sql.Named("date", task.Date),
 		sql.Named("title", task.Title),
 		sql.Named("comment", task.Comment),
 		sql.Named("repeat", task.Repeat),
-		sql.Named("id", task.ID))
+		sql.Named("until", task.Until),
+		sql.Named("remaining", task.Remaining),
+		sql.Named("time", task.Time),
+		sql.Named("duration", task.Duration),
+		sql.Named("from_completion", task.FromCompletion),
+		sql.Named("exdates", strings.Join(task.Exdates, ",")),
+		sql.Named("ease", task.Ease),
+		sql.Named("interval", task.Interval),
+		sql.Named("repetitions", task.Repetitions),
+		sql.Named("priority", task.Priority),
+		sql.Named("list_id", task.ListID),
+		sql.Named("anchor", task.Anchor),
+		sql.Named("version", task.Version),
+		sql.Named("id", task.ID)).Scan(&task.Version, &task.UpdatedAt)
+	if err == sql.ErrNoRows {
+		var version int
+		err := tx.QueryRow("SELECT version FROM scheduler WHERE id = :id AND archived = 0 AND deleted_at = ''",
+			sql.Named("id", task.ID)).Scan(&version)
+		if err == nil {
+			loger.L.Error("task version conflict", "id", task.ID, "version", task.Version, "current", version)
+			return fmt.Errorf("task %s has version %d: %w", task.ID, version, api.ErrVersionConflict)
+		}
+		loger.L.Error("no task found", "id", task.ID)
+		return fmt.Errorf("no task found with id %s", task.ID)
+	}
 	if err != nil {
 		loger.L.Error("failed to update task", "id", task.ID, "error", err)
-		return fmt.Errorf("t.SqlStorage.Exec: failed to update task with id %s: %w", task.ID, err)
+		return fmt.Errorf("tx.QueryRow: failed to update task with id %s: %w", task.ID, err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		loger.L.Error("failed to get rows affected", "id", task.ID, "error", err)
-		return fmt.Errorf("result.RowsAffected: failed to check rows affected for id %s: %w", task.ID, err)
+	if err := setTags(tx, task.ID, task.Tags); err != nil {
+		return fmt.Errorf("setTags: %w", err)
 	}
-	if rowsAffected == 0 {
-		loger.L.Error("no task found", "id", task.ID)
-		return fmt.Errorf("no task found with id %s", task.ID)
+	// без subtasks в запросе чек-лист остаётся прежним
+	if task.Subtasks != nil {
+		if err := setSubtasks(tx, task.ID, task.Subtasks); err != nil {
+			return fmt.Errorf("setSubtasks: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit: cannot commit task: %w", err)
 	}
 
 	loger.L.Info("task updated successfully", "id", task.ID)
 	return nil
 }
 
+// DeleteTask переносит задачу в корзину. Окончательно задача удаляется
+// PurgeTrash, а до этого её можно вернуть RestoreTask.
 func (t *TaskStorage) DeleteTask(id string) error {
-	res, err := t.SqlStorage.Exec("DELETE FROM scheduler WHERE id = :id", sql.Named("id", id))
+	res, err := t.SqlStorage.Exec(`
+		UPDATE scheduler SET deleted_at = :deleted_at, `+touch+`
+		WHERE id = :id AND archived = 0 AND deleted_at = ''`,
+		sql.Named("deleted_at", time.Now().UTC().Format(time.RFC3339)),
+		sql.Named("id", id))
 	if err != nil {
 		loger.L.Error("failed to delete task", "id", id, "error", err)
 		return fmt.Errorf("t.SqlStorage.Exec: failed to delete task with id %s: %w", id, err)
@@ -206,28 +556,845 @@ func (t *TaskStorage) DeleteTask(id string) error {
 	return nil
 }
 
-func (t *TaskStorage) UpdateDate(next string, id string) error {
-	result, err := t.SqlStorage.Exec(`
-        UPDATE scheduler 
-        SET date = :date 
-        WHERE id = :id`,
-		sql.Named("date", next),
+// GetTrash возвращает задачи из корзины, последние удалённые первыми.
+func (t *TaskStorage) GetTrash(limit int) ([]api.Task, error) {
+	rows, err := t.SqlStorage.Query(`
+		SELECT `+taskColumns+` FROM scheduler
+		WHERE deleted_at != ''
+		ORDER BY deleted_at DESC, id DESC
+		LIMIT :limit`,
+		sql.Named("limit", limit))
+	if err != nil {
+		return nil, fmt.Errorf("t.SqlStorage.Query: cannot do SELECT: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := make([]api.Task, 0)
+	for rows.Next() {
+		task := api.Task{}
+		if err := scanTask(rows, &task); err != nil {
+			return nil, fmt.Errorf("rows.Scan: cannot do Scan: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: err in rows: %w", err)
+	}
+	if err := t.loadTags(tasks); err != nil {
+		return nil, fmt.Errorf("t.loadTags: %w", err)
+	}
+	return tasks, nil
+}
+
+// RestoreTask возвращает задачу из корзины.
+func (t *TaskStorage) RestoreTask(id string) error {
+	res, err := t.SqlStorage.Exec("UPDATE scheduler SET deleted_at = '', "+touch+" WHERE id = :id AND deleted_at != ''",
 		sql.Named("id", id))
 	if err != nil {
-		loger.L.Error("failed to update task", "id", id, "error", err)
-		return fmt.Errorf("t.SqlStorage.Exec: failed to update task with id %s: %w", id, err)
+		loger.L.Error("failed to restore task", "id", id, "error", err)
+		return fmt.Errorf("t.SqlStorage.Exec: failed to restore task with id %s: %w", id, err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	rowsAffected, err := res.RowsAffected()
 	if err != nil {
 		loger.L.Error("failed to get rows affected", "id", id, "error", err)
 		return fmt.Errorf("result.RowsAffected: failed to check rows affected for id %s: %w", id, err)
 	}
 	if rowsAffected == 0 {
-		loger.L.Error("no task found", "id", id)
-		return fmt.Errorf("no task found with id %s", id)
+		loger.L.Error("no task in trash", "id", id)
+		return fmt.Errorf("no task in trash with id %s", id)
+	}
+
+	loger.L.Info("task restored successfully", "id", id)
+	return nil
+}
+
+// PurgeTrash окончательно удаляет задачи, перенесённые в корзину раньше before,
+// и возвращает их число.
+func (t *TaskStorage) PurgeTrash(before time.Time) (int64, error) {
+	res, err := t.SqlStorage.Exec("DELETE FROM scheduler WHERE deleted_at != '' AND deleted_at < :before",
+		sql.Named("before", before.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return 0, fmt.Errorf("t.SqlStorage.Exec: failed to purge trash: %w", err)
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("result.RowsAffected: failed to check purged tasks: %w", err)
+	}
+
+	// метки, подзадачи и зависимости удалённых задач больше не нужны
+	_, err = t.SqlStorage.Exec(`
+		DELETE FROM task_tags WHERE task_id NOT IN (SELECT id FROM scheduler);
+		DELETE FROM subtasks WHERE task_id NOT IN (SELECT id FROM scheduler);
+		DELETE FROM dependencies WHERE task_id NOT IN (SELECT id FROM scheduler)
+			OR blocker_id NOT IN (SELECT id FROM scheduler);`)
+	if err != nil {
+		return purged, fmt.Errorf("t.SqlStorage.Exec: failed to purge relations of deleted tasks: %w", err)
+	}
+	return purged, nil
+}
+
+// CompleteTask отмечает задачу выполненной в одной транзакции: записывает
+// выполнение c и переносит задачу в состояние next, а если next равен nil —
+// в архив. У следующего повторения чек-лист начинается заново.
+func (t *TaskStorage) CompleteTask(c api.Completion, next *api.Task) error {
+	tx, err := t.SqlStorage.Begin()
+	if err != nil {
+		return fmt.Errorf("t.SqlStorage.Begin: cannot start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var res sql.Result
+	if next == nil {
+		res, err = tx.Exec("UPDATE scheduler SET archived = 1, "+touch+" WHERE id = :id AND archived = 0 AND deleted_at = ''",
+			sql.Named("id", c.TaskID))
+	} else {
+		res, err = tx.Exec(`
+			UPDATE scheduler
+			SET date = :date, remaining = :remaining, ease = :ease, interval = :interval,
+				repetitions = :repetitions, anchor = :anchor, `+touch+`
+			WHERE id = :id AND archived = 0 AND deleted_at = ''`,
+			sql.Named("date", next.Date),
+			sql.Named("anchor", next.Anchor),
+			sql.Named("remaining", next.Remaining),
+			sql.Named("ease", next.Ease),
+			sql.Named("interval", next.Interval),
+			sql.Named("repetitions", next.Repetitions),
+			sql.Named("id", c.TaskID))
+	}
+	if err != nil {
+		loger.L.Error("failed to complete task", "id", c.TaskID, "error", err)
+		return fmt.Errorf("tx.Exec: failed to complete task with id %s: %w", c.TaskID, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		loger.L.Error("failed to get rows affected", "id", c.TaskID, "error", err)
+		return fmt.Errorf("result.RowsAffected: failed to check rows affected for id %s: %w", c.TaskID, err)
+	}
+	if rowsAffected == 0 {
+		loger.L.Error("no task found", "id", c.TaskID)
+		return fmt.Errorf("no task found with id %s", c.TaskID)
+	}
+
+	if next != nil {
+		_, err := tx.Exec("UPDATE subtasks SET done = 0 WHERE task_id = :task_id", sql.Named("task_id", c.TaskID))
+		if err != nil {
+			return fmt.Errorf("tx.Exec: failed to reset subtasks of task %s: %w", c.TaskID, err)
+		}
+	}
+
+	doneJSON, err := encodeIDs(c.DoneSubtasks)
+	if err != nil {
+		return fmt.Errorf("encodeIDs: cannot encode done subtasks: %w", err)
+	}
+	_, err = tx.Exec(`INSERT INTO completions
+		(task_id, title, date, completed_at, remaining, ease, interval, repetitions, archived, done_subtasks, anchor)
+		VALUES (:task_id, :title, :date, :completed_at, :remaining, :ease, :interval, :repetitions, :archived,
+			:done_subtasks, :anchor)`,
+		sql.Named("task_id", c.TaskID),
+		sql.Named("title", c.Title),
+		sql.Named("date", c.Date),
+		sql.Named("completed_at", c.CompletedAt),
+		sql.Named("remaining", c.Remaining),
+		sql.Named("ease", c.Ease),
+		sql.Named("interval", c.Interval),
+		sql.Named("repetitions", c.Repetitions),
+		sql.Named("archived", c.Archived),
+		sql.Named("done_subtasks", doneJSON),
+		sql.Named("anchor", c.Anchor))
+	if err != nil {
+		return fmt.Errorf("tx.Exec: error by inserting completion: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit: cannot commit completion: %w", err)
+	}
+
+	loger.L.Info("task completed successfully", "id", c.TaskID, "archived", next == nil)
+	return nil
+}
+
+// GetLastCompletion возвращает последнее выполнение задачи вместе с её
+// состоянием до выполнения.
+func (t *TaskStorage) GetLastCompletion(taskID string) (*api.Completion, error) {
+	row := t.SqlStorage.QueryRow(`
+		SELECT id, task_id, title, date, completed_at, remaining, ease, interval, repetitions, archived, done_subtasks,
+			anchor
+		FROM completions WHERE task_id = :task_id
+		ORDER BY id DESC LIMIT 1`,
+		sql.Named("task_id", taskID))
+
+	c := &api.Completion{}
+	var doneJSON string
+	err := row.Scan(&c.ID, &c.TaskID, &c.Title, &c.Date, &c.CompletedAt,
+		&c.Remaining, &c.Ease, &c.Interval, &c.Repetitions, &c.Archived, &doneJSON, &c.Anchor)
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan: cannot get last completion of task %s: %w", taskID, err)
+	}
+	if err := json.Unmarshal([]byte(doneJSON), &c.DoneSubtasks); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: cannot decode done subtasks of task %s: %w", taskID, err)
+	}
+	return c, nil
+}
+
+// UndoCompletion отменяет выполнение c: возвращает задаче прежние дату,
+// состояние серии и чек-лист, достаёт её из архива и удаляет запись о выполнении.
+func (t *TaskStorage) UndoCompletion(c *api.Completion) error {
+	doneJSON, err := encodeIDs(c.DoneSubtasks)
+	if err != nil {
+		return fmt.Errorf("encodeIDs: cannot encode done subtasks: %w", err)
+	}
+
+	tx, err := t.SqlStorage.Begin()
+	if err != nil {
+		return fmt.Errorf("t.SqlStorage.Begin: cannot start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE scheduler
+		SET date = :date, remaining = :remaining, ease = :ease, interval = :interval,
+			repetitions = :repetitions, anchor = :anchor, archived = 0, `+touch+`
+		WHERE id = :id AND archived = :archived AND deleted_at = ''`,
+		sql.Named("date", c.Date),
+		sql.Named("anchor", c.Anchor),
+		sql.Named("remaining", c.Remaining),
+		sql.Named("ease", c.Ease),
+		sql.Named("interval", c.Interval),
+		sql.Named("repetitions", c.Repetitions),
+		sql.Named("archived", c.Archived),
+		sql.Named("id", c.TaskID))
+	if err != nil {
+		loger.L.Error("failed to undo completion", "id", c.TaskID, "error", err)
+		return fmt.Errorf("tx.Exec: failed to undo completion of task %s: %w", c.TaskID, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("result.RowsAffected: failed to check rows affected for id %s: %w", c.TaskID, err)
+	}
+	if rowsAffected == 0 {
+		loger.L.Error("no task found", "id", c.TaskID)
+		return fmt.Errorf("no task found with id %s", c.TaskID)
+	}
+
+	// чек-лист возвращается к состоянию на момент выполнения
+	_, err = tx.Exec(`UPDATE subtasks SET done = id IN (SELECT CAST(value AS INTEGER) FROM json_each(:done))
+		WHERE task_id = :task_id`,
+		sql.Named("done", doneJSON),
+		sql.Named("task_id", c.TaskID))
+	if err != nil {
+		return fmt.Errorf("tx.Exec: failed to restore subtasks of task %s: %w", c.TaskID, err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM completions WHERE id = :id", sql.Named("id", c.ID)); err != nil {
+		return fmt.Errorf("tx.Exec: failed to delete completion %s: %w", c.ID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit: cannot commit undo: %w", err)
+	}
+
+	loger.L.Info("completion undone successfully", "id", c.TaskID, "date", c.Date)
+	return nil
+}
+
+// GetHistory возвращает выполнения от новых к старым. from и to ограничивают
+// момент выполнения полуинтервалом [from, to) в RFC 3339 (UTC), taskID — задачу;
+// пустое значение означает, что ограничения нет.
+func (t *TaskStorage) GetHistory(limit int, from, to, taskID string) ([]api.Completion, error) {
+	rows, err := t.SqlStorage.Query(`
+		SELECT id, task_id, title, date, completed_at FROM completions
+		WHERE (:from = '' OR completed_at >= :from)
+		AND (:to = '' OR completed_at < :to)
+		AND (:task_id = '' OR task_id = :task_id)
+		ORDER BY completed_at DESC, id DESC
+		LIMIT :limit`,
+		sql.Named("from", from),
+		sql.Named("to", to),
+		sql.Named("task_id", taskID),
+		sql.Named("limit", limit))
+	if err != nil {
+		return nil, fmt.Errorf("t.SqlStorage.Query: cannot do SELECT: %w", err)
+	}
+	defer rows.Close()
+
+	completions := make([]api.Completion, 0)
+	for rows.Next() {
+		c := api.Completion{}
+		if err := rows.Scan(&c.ID, &c.TaskID, &c.Title, &c.Date, &c.CompletedAt); err != nil {
+			return nil, fmt.Errorf("rows.Scan: cannot do Scan: %w", err)
+		}
+		completions = append(completions, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: err in rows: %w", err)
+	}
+	return completions, nil
+}
+
+func (t *TaskStorage) GetHolidays() ([]calendar.Day, error) {
+	rows, err := t.SqlStorage.Query("SELECT date, title, workday FROM holidays ORDER BY date")
+	if err != nil {
+		return nil, fmt.Errorf("t.SqlStorage.Query: cannot do SELECT: %w", err)
+	}
+	defer rows.Close()
+
+	days := make([]calendar.Day, 0)
+	for rows.Next() {
+		day := calendar.Day{}
+		if err := rows.Scan(&day.Date, &day.Title, &day.Workday); err != nil {
+			return nil, fmt.Errorf("rows.Scan: cannot do Scan: %w", err)
+		}
+		days = append(days, day)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: err in rows: %w", err)
+	}
+	return days, nil
+}
+
+func (t *TaskStorage) AddHoliday(day calendar.Day) error {
+	_, err := t.SqlStorage.Exec(`INSERT OR REPLACE INTO holidays (date, title, workday) VALUES (:date, :title, :workday)`,
+		sql.Named("date", day.Date),
+		sql.Named("title", day.Title),
+		sql.Named("workday", day.Workday))
+	if err != nil {
+		loger.L.Error("failed to add holiday", "date", day.Date, "error", err)
+		return fmt.Errorf("t.SqlStorage.Exec: error by inserting holiday: %w", err)
+	}
+
+	loger.L.Info("holiday added successfully", "date", day.Date)
+	return nil
+}
+
+func (t *TaskStorage) DeleteHoliday(date string) error {
+	res, err := t.SqlStorage.Exec("DELETE FROM holidays WHERE date = :date", sql.Named("date", date))
+	if err != nil {
+		loger.L.Error("failed to delete holiday", "date", date, "error", err)
+		return fmt.Errorf("t.SqlStorage.Exec: failed to delete holiday %s: %w", date, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		loger.L.Error("failed to get rows affected", "date", date, "error", err)
+		return fmt.Errorf("result.RowsAffected: failed to check rows affected for %s: %w", date, err)
+	}
+	if rowsAffected == 0 {
+		loger.L.Error("no holiday found", "date", date)
+		return fmt.Errorf("no holiday found with date %s", date)
+	}
+
+	loger.L.Info("holiday deleted successfully", "date", date)
+	return nil
+}
+
+// setSubtasks заменяет чек-лист задачи; порядок пунктов сохраняется. Пункты
+// с id этой задачи обновляются на месте, чтобы их id не менялись, остальные
+// добавляются заново, а пропавшие из чек-листа удаляются.
+func setSubtasks(db execer, taskID any, subtasks []api.Subtask) error {
+	ids := make([]string, 0, len(subtasks))
+	for _, s := range subtasks {
+		if s.ID != "" {
+			ids = append(ids, s.ID)
+		}
+	}
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: cannot encode subtask ids: %w", err)
+	}
+	_, err = db.Exec(`DELETE FROM subtasks WHERE task_id = :task_id
+		AND id NOT IN (SELECT CAST(value AS INTEGER) FROM json_each(:ids))`,
+		sql.Named("task_id", taskID),
+		sql.Named("ids", string(idsJSON)))
+	if err != nil {
+		return fmt.Errorf("db.Exec: cannot clear subtasks of task %v: %w", taskID, err)
+	}
+
+	for i, s := range subtasks {
+		if s.ID != "" {
+			res, err := db.Exec(`UPDATE subtasks SET position = :position, title = :title, done = :done
+				WHERE id = :id AND task_id = :task_id`,
+				sql.Named("position", i),
+				sql.Named("title", s.Title),
+				sql.Named("done", s.Done),
+				sql.Named("id", s.ID),
+				sql.Named("task_id", taskID))
+			if err != nil {
+				return fmt.Errorf("db.Exec: cannot update subtask %s: %w", s.ID, err)
+			}
+			if rowsAffected, err := res.RowsAffected(); err != nil {
+				return fmt.Errorf("result.RowsAffected: failed to check rows affected for subtask %s: %w", s.ID, err)
+			} else if rowsAffected > 0 {
+				continue
+			}
+		}
+		_, err := db.Exec(`INSERT INTO subtasks (task_id, position, title, done)
+			VALUES (:task_id, :position, :title, :done)`,
+			sql.Named("task_id", taskID),
+			sql.Named("position", i),
+			sql.Named("title", s.Title),
+			sql.Named("done", s.Done))
+		if err != nil {
+			return fmt.Errorf("db.Exec: cannot add subtask to task %v: %w", taskID, err)
+		}
+	}
+	return nil
+}
+
+// loadSubtasks заполняет чек-лист задачи и долю выполненных пунктов.
+func (t *TaskStorage) loadSubtasks(task *api.Task) error {
+	rows, err := t.SqlStorage.Query("SELECT id, title, done FROM subtasks WHERE task_id = :task_id ORDER BY position",
+		sql.Named("task_id", task.ID))
+	if err != nil {
+		return fmt.Errorf("t.SqlStorage.Query: cannot do SELECT: %w", err)
+	}
+	defer rows.Close()
+
+	task.Subtasks = nil
+	for rows.Next() {
+		var s api.Subtask
+		if err := rows.Scan(&s.ID, &s.Title, &s.Done); err != nil {
+			return fmt.Errorf("rows.Scan: cannot do Scan: %w", err)
+		}
+		task.Subtasks = append(task.Subtasks, s)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows.Err: err in rows: %w", err)
+	}
+	task.Progress = api.Progress(task.Subtasks)
+	return nil
+}
+
+// SetSubtaskDone отмечает подзадачу задачи из списка выполненной или открытой
+// и возвращает id задачи.
+func (t *TaskStorage) SetSubtaskDone(id string, done bool) (string, error) {
+	var taskID string
+	err := t.SqlStorage.QueryRow(`
+		UPDATE subtasks SET done = :done
+		WHERE id = :id AND task_id IN (SELECT id FROM scheduler WHERE archived = 0 AND deleted_at = '')
+		RETURNING task_id`,
+		sql.Named("done", done),
+		sql.Named("id", id)).Scan(&taskID)
+	if err == sql.ErrNoRows {
+		loger.L.Error("no subtask found", "id", id)
+		return "", fmt.Errorf("no subtask found with id %s", id)
+	}
+	if err != nil {
+		loger.L.Error("failed to update subtask", "id", id, "error", err)
+		return "", fmt.Errorf("t.SqlStorage.QueryRow: failed to update subtask with id %s: %w", id, err)
+	}
+	// чек-лист — часть задачи, поэтому её версия тоже растёт
+	if _, err := t.SqlStorage.Exec("UPDATE scheduler SET "+touch+" WHERE id = :id", sql.Named("id", taskID)); err != nil {
+		return "", fmt.Errorf("t.SqlStorage.Exec: failed to touch task %s: %w", taskID, err)
+	}
+
+	loger.L.Info("subtask updated successfully", "id", id, "done", done)
+	return taskID, nil
+}
+
+// loadDependencies заполняет открытые задачи, которые блокируют задачи из tasks
+// и которые ими заблокированы.
+func (t *TaskStorage) loadDependencies(tasks []api.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]string, len(tasks))
+	byID := make(map[string]*api.Task, len(tasks))
+	for i := range tasks {
+		ids[i] = tasks[i].ID
+		byID[tasks[i].ID] = &tasks[i]
+	}
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: cannot encode ids: %w", err)
+	}
+
+	rows, err := t.SqlStorage.Query(`
+		SELECT dependencies.task_id, dependencies.blocker_id FROM dependencies
+		JOIN scheduler AS task ON task.id = dependencies.task_id
+		JOIN scheduler AS blocker ON blocker.id = dependencies.blocker_id
+		WHERE (dependencies.task_id IN (SELECT value FROM json_each(:ids))
+			OR dependencies.blocker_id IN (SELECT value FROM json_each(:ids)))
+		AND task.archived = 0 AND task.deleted_at = ''
+		AND `+fmt.Sprintf(blocking, "task")+`
+		ORDER BY dependencies.task_id, dependencies.blocker_id`,
+		sql.Named("ids", string(idsJSON)))
+	if err != nil {
+		return fmt.Errorf("t.SqlStorage.Query: cannot do SELECT: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID, blockerID string
+		if err := rows.Scan(&taskID, &blockerID); err != nil {
+			return fmt.Errorf("rows.Scan: cannot do Scan: %w", err)
+		}
+		if task, ok := byID[taskID]; ok {
+			task.BlockedBy = append(task.BlockedBy, blockerID)
+		}
+		if blocker, ok := byID[blockerID]; ok {
+			blocker.Blocks = append(blocker.Blocks, taskID)
+		}
+	}
+	return rows.Err()
+}
+
+// AddDependency делает задачу taskID зависимой от blockerID. Если blockerID
+// уже зависит от taskID, напрямую или через другие задачи, возвращается ErrDependencyCycle.
+func (t *TaskStorage) AddDependency(taskID, blockerID string) error {
+	tx, err := t.SqlStorage.Begin()
+	if err != nil {
+		return fmt.Errorf("t.SqlStorage.Begin: cannot start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var cycle bool
+	err = tx.QueryRow(`
+		WITH RECURSIVE chain(id) AS (
+			SELECT CAST(:blocker_id AS INTEGER)
+			UNION
+			SELECT dependencies.blocker_id FROM dependencies JOIN chain ON dependencies.task_id = chain.id
+		)
+		SELECT EXISTS (SELECT 1 FROM chain WHERE id = CAST(:task_id AS INTEGER))`,
+		sql.Named("blocker_id", blockerID),
+		sql.Named("task_id", taskID)).Scan(&cycle)
+	if err != nil {
+		return fmt.Errorf("tx.QueryRow: cannot check dependency cycle: %w", err)
+	}
+	if cycle {
+		return fmt.Errorf("task %s blocked by %s: %w", taskID, blockerID, api.ErrDependencyCycle)
+	}
+
+	_, err = tx.Exec("INSERT OR IGNORE INTO dependencies (task_id, blocker_id) VALUES (:task_id, :blocker_id)",
+		sql.Named("task_id", taskID),
+		sql.Named("blocker_id", blockerID))
+	if err != nil {
+		return fmt.Errorf("tx.Exec: error by inserting dependency: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit: cannot commit dependency: %w", err)
+	}
+
+	loger.L.Info("dependency added successfully", "id", taskID, "blocker", blockerID)
+	return nil
+}
+
+func (t *TaskStorage) DeleteDependency(taskID, blockerID string) error {
+	res, err := t.SqlStorage.Exec("DELETE FROM dependencies WHERE task_id = :task_id AND blocker_id = :blocker_id",
+		sql.Named("task_id", taskID),
+		sql.Named("blocker_id", blockerID))
+	if err != nil {
+		return fmt.Errorf("t.SqlStorage.Exec: failed to delete dependency: %w", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("result.RowsAffected: failed to check rows affected for id %s: %w", taskID, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("task %s blocked by %s: %w", taskID, blockerID, api.ErrDependencyNotFound)
+	}
+
+	loger.L.Info("dependency deleted successfully", "id", taskID, "blocker", blockerID)
+	return nil
+}
+
+// GetLists возвращает списки по порядку создания с числом задач в каждом.
+func (t *TaskStorage) GetLists() ([]api.List, error) {
+	rows, err := t.SqlStorage.Query(`
+		SELECT lists.id, lists.name, count(scheduler.id) FROM lists
+		LEFT JOIN scheduler ON scheduler.list_id = lists.id
+			AND scheduler.archived = 0 AND scheduler.deleted_at = ''
+		GROUP BY lists.id
+		ORDER BY lists.id`)
+	if err != nil {
+		return nil, fmt.Errorf("t.SqlStorage.Query: cannot do SELECT: %w", err)
+	}
+	defer rows.Close()
+
+	lists := make([]api.List, 0)
+	for rows.Next() {
+		var list api.List
+		if err := rows.Scan(&list.ID, &list.Name, &list.Tasks); err != nil {
+			return nil, fmt.Errorf("rows.Scan: cannot do Scan: %w", err)
+		}
+		lists = append(lists, list)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: err in rows: %w", err)
+	}
+	return lists, nil
+}
+
+func (t *TaskStorage) GetList(id string) (*api.List, error) {
+	list := &api.List{}
+	err := t.SqlStorage.QueryRow("SELECT id, name FROM lists WHERE id = :id", sql.Named("id", id)).
+		Scan(&list.ID, &list.Name)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no list with id %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("t.SqlStorage.QueryRow: failed to get list with id %s: %w", id, err)
+	}
+	return list, nil
+}
+
+func (t *TaskStorage) AddList(name string) (int64, error) {
+	res, err := t.SqlStorage.Exec("INSERT INTO lists (name) VALUES (:name)", sql.Named("name", name))
+	if isUnique(err) {
+		return 0, fmt.Errorf("list %s: %w", name, api.ErrListExists)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("t.SqlStorage.Exec: error by inserting list: %w", err)
+	}
+
+	return res.LastInsertId()
+}
+
+func (t *TaskStorage) RenameList(id, name string) error {
+	res, err := t.SqlStorage.Exec("UPDATE lists SET name = :name WHERE id = :id",
+		sql.Named("name", name),
+		sql.Named("id", id))
+	if isUnique(err) {
+		return fmt.Errorf("list %s: %w", name, api.ErrListExists)
+	}
+	if err != nil {
+		loger.L.Error("failed to rename list", "id", id, "error", err)
+		return fmt.Errorf("t.SqlStorage.Exec: failed to rename list with id %s: %w", id, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("result.RowsAffected: failed to check rows affected for id %s: %w", id, err)
+	}
+	if rowsAffected == 0 {
+		loger.L.Error("no list found", "id", id)
+		return fmt.Errorf("no list found with id %s", id)
+	}
+
+	loger.L.Info("list renamed successfully", "id", id, "name", name)
+	return nil
+}
+
+// DeleteList удаляет список и переносит все его задачи, включая архив
+// и корзину, во «Входящие».
+func (t *TaskStorage) DeleteList(id string) error {
+	if id == api.DefaultListID {
+		return api.ErrDefaultList
+	}
+
+	tx, err := t.SqlStorage.Begin()
+	if err != nil {
+		return fmt.Errorf("t.SqlStorage.Begin: cannot start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM lists WHERE id = :id", sql.Named("id", id))
+	if err != nil {
+		loger.L.Error("failed to delete list", "id", id, "error", err)
+		return fmt.Errorf("tx.Exec: failed to delete list with id %s: %w", id, err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("result.RowsAffected: failed to check rows affected for id %s: %w", id, err)
+	}
+	if rowsAffected == 0 {
+		loger.L.Error("no list found", "id", id)
+		return fmt.Errorf("no list found with id %s", id)
+	}
+
+	_, err = tx.Exec("UPDATE scheduler SET list_id = :default WHERE list_id = :id",
+		sql.Named("default", api.DefaultListID),
+		sql.Named("id", id))
+	if err != nil {
+		return fmt.Errorf("tx.Exec: failed to move tasks of list %s: %w", id, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit: cannot commit list deletion: %w", err)
+	}
+
+	loger.L.Info("list deleted successfully", "id", id)
+	return nil
+}
+
+// encodeIDs записывает id как JSON-массив для json_each; nil становится пустым массивом.
+func encodeIDs(ids []string) (string, error) {
+	if ids == nil {
+		ids = []string{}
+	}
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %w", err)
+	}
+	return string(data), nil
+}
+
+// execer — *sql.DB или *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// setTags заменяет метки задачи; недостающие метки создаются.
+func setTags(db execer, taskID any, tags []string) error {
+	if _, err := db.Exec("DELETE FROM task_tags WHERE task_id = :task_id", sql.Named("task_id", taskID)); err != nil {
+		return fmt.Errorf("db.Exec: cannot clear tags of task %v: %w", taskID, err)
+	}
+	for _, tag := range tags {
+		if _, err := db.Exec("INSERT OR IGNORE INTO tags (name) VALUES (:name)", sql.Named("name", tag)); err != nil {
+			return fmt.Errorf("db.Exec: cannot add tag %s: %w", tag, err)
+		}
+		_, err := db.Exec(`INSERT INTO task_tags (task_id, tag_id) SELECT :task_id, id FROM tags WHERE name = :name`,
+			sql.Named("task_id", taskID),
+			sql.Named("name", tag))
+		if err != nil {
+			return fmt.Errorf("db.Exec: cannot tag task %v with %s: %w", taskID, tag, err)
+		}
+	}
+	return nil
+}
+
+// loadTags заполняет метки задач.
+func (t *TaskStorage) loadTags(tasks []api.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]string, len(tasks))
+	byID := make(map[string]*api.Task, len(tasks))
+	for i := range tasks {
+		ids[i] = tasks[i].ID
+		byID[tasks[i].ID] = &tasks[i]
+	}
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: cannot encode ids: %w", err)
+	}
+
+	rows, err := t.SqlStorage.Query(`
+		SELECT task_tags.task_id, tags.name FROM task_tags JOIN tags ON tags.id = task_tags.tag_id
+		WHERE task_tags.task_id IN (SELECT value FROM json_each(:ids))
+		ORDER BY tags.name`,
+		sql.Named("ids", string(idsJSON)))
+	if err != nil {
+		return fmt.Errorf("t.SqlStorage.Query: cannot do SELECT: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return fmt.Errorf("rows.Scan: cannot do Scan: %w", err)
+		}
+		if task, ok := byID[id]; ok {
+			task.Tags = append(task.Tags, name)
+		}
+	}
+	return rows.Err()
+}
+
+// GetTags возвращает метки по алфавиту с числом задач в списке, у которых они есть.
+func (t *TaskStorage) GetTags() ([]api.Tag, error) {
+	rows, err := t.SqlStorage.Query(`
+		SELECT tags.id, tags.name, count(scheduler.id) FROM tags
+		LEFT JOIN task_tags ON task_tags.tag_id = tags.id
+		LEFT JOIN scheduler ON scheduler.id = task_tags.task_id
+			AND scheduler.archived = 0 AND scheduler.deleted_at = ''
+		GROUP BY tags.id
+		ORDER BY tags.name`)
+	if err != nil {
+		return nil, fmt.Errorf("t.SqlStorage.Query: cannot do SELECT: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make([]api.Tag, 0)
+	for rows.Next() {
+		var tag api.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Tasks); err != nil {
+			return nil, fmt.Errorf("rows.Scan: cannot do Scan: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows.Err: err in rows: %w", err)
+	}
+	return tags, nil
+}
+
+// isUnique сообщает, нарушено ли ограничение UNIQUE.
+func isUnique(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+func (t *TaskStorage) AddTag(name string) (int64, error) {
+	res, err := t.SqlStorage.Exec("INSERT INTO tags (name) VALUES (:name)", sql.Named("name", name))
+	if isUnique(err) {
+		return 0, fmt.Errorf("tag %s: %w", name, api.ErrTagExists)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("t.SqlStorage.Exec: error by inserting tag: %w", err)
+	}
+
+	return res.LastInsertId()
+}
+
+func (t *TaskStorage) RenameTag(id, name string) error {
+	res, err := t.SqlStorage.Exec("UPDATE tags SET name = :name WHERE id = :id",
+		sql.Named("name", name),
+		sql.Named("id", id))
+	if isUnique(err) {
+		return fmt.Errorf("tag %s: %w", name, api.ErrTagExists)
+	}
+	if err != nil {
+		loger.L.Error("failed to rename tag", "id", id, "error", err)
+		return fmt.Errorf("t.SqlStorage.Exec: failed to rename tag with id %s: %w", id, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("result.RowsAffected: failed to check rows affected for id %s: %w", id, err)
+	}
+	if rowsAffected == 0 {
+		loger.L.Error("no tag found", "id", id)
+		return fmt.Errorf("no tag found with id %s", id)
+	}
+
+	loger.L.Info("tag renamed successfully", "id", id, "name", name)
+	return nil
+}
+
+// DeleteTag удаляет метку и снимает её со всех задач.
+func (t *TaskStorage) DeleteTag(id string) error {
+	tx, err := t.SqlStorage.Begin()
+	if err != nil {
+		return fmt.Errorf("t.SqlStorage.Begin: cannot start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM tags WHERE id = :id", sql.Named("id", id))
+	if err != nil {
+		loger.L.Error("failed to delete tag", "id", id, "error", err)
+		return fmt.Errorf("tx.Exec: failed to delete tag with id %s: %w", id, err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("result.RowsAffected: failed to check rows affected for id %s: %w", id, err)
+	}
+	if rowsAffected == 0 {
+		loger.L.Error("no tag found", "id", id)
+		return fmt.Errorf("no tag found with id %s", id)
+	}
+
+	if _, err := tx.Exec("DELETE FROM task_tags WHERE tag_id = :id", sql.Named("id", id)); err != nil {
+		return fmt.Errorf("tx.Exec: failed to untag tasks with tag %s: %w", id, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("tx.Commit: cannot commit tag deletion: %w", err)
 	}
 
-	loger.L.Info("task updated successfully", "id", id)
+	loger.L.Info("tag deleted successfully", "id", id)
 	return nil
 }
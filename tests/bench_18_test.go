@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/NarthurN/TODO-API-web/pkg/api"
+)
+
+// Бенчмарки не обращаются к серверу: go test -run ^$ -bench . ./tests
+
+var benchNow = time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+
+// benchAges — давность даты задачи: время расчёта не должно от неё зависеть.
+var benchAges = []struct {
+	name string
+	date string
+}{
+	{"week", "20240119"},
+	{"year", "20230126"},
+	{"30years", "19940126"},
+}
+
+// stepNextDate — прежний расчёт правил d и w перебором по одному шагу,
+// с ним сравнивается RepeatRule.
+func stepNextDate(now, start time.Time, days int, weekdays []time.Weekday) time.Time {
+	if days > 0 {
+		for {
+			start = start.AddDate(0, 0, days)
+			if start.After(now) {
+				return start
+			}
+		}
+	}
+	for {
+		start = start.AddDate(0, 0, 1)
+		if slices.Contains(weekdays, start.Weekday()) && start.After(now) {
+			return start
+		}
+	}
+}
+
+func BenchmarkNextDate(b *testing.B) {
+	for _, repeat := range []string{"d 1", "y", "w 1,4", "m 1,-1", "n 2:2", "b 3", "d 7 >"} {
+		for _, age := range benchAges {
+			b.Run(repeat+"/"+age.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := api.NextDate(benchNow, age.date, repeat); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkRepeatRule(b *testing.B) {
+	for _, repeat := range []string{"d 1", "w 1,4"} {
+		rule, err := api.ParseRepeat(repeat)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, age := range benchAges {
+			start, _ := time.Parse(api.Layout, age.date)
+			b.Run(repeat+"/"+age.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := rule.Next(benchNow, start); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkStepwise(b *testing.B) {
+	for _, age := range benchAges {
+		start, _ := time.Parse(api.Layout, age.date)
+		b.Run("d 1/"+age.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				stepNextDate(benchNow, start, 1, nil)
+			}
+		})
+		b.Run("w 1,4/"+age.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				stepNextDate(benchNow, start, 0, []time.Weekday{time.Monday, time.Thursday})
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func checkNextDates(t *testing.T, tbl []nextDate) {
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`,
+			v.date, v.repeat, v.want)
+	}
+}
+
+func TestNextDateBusinessDays(t *testing.T) {
+	checkNextDates(t, []nextDate{
+		{"20240126", "b 1", "20240129"},
+		{"20240124", "b 3", "20240129"},
+		{"20240126", "d 1 >", "20240129"},
+		{"20240126", "d 1 <", "20240129"},
+		{"20240126", "m 10 >", "20240212"},
+		{"20230127", "y >", "20240129"},
+		{"20240126", "w 1 >", ""},
+		{"20240126", "d 7 > >", ""},
+		{"20240126", "b 0", ""},
+		{"20240126", "b", ""},
+	})
+
+	ret, err := postJSON("api/holiday", map[string]any{
+		"date":  "20240129",
+		"title": "Тестовый праздник",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+
+	checkNextDates(t, []nextDate{
+		{"20240126", "b 1", "20240130"},
+		{"20240126", "d 1 >", "20240130"},
+	})
+
+	ret, err = postJSON("api/holiday?date=20240129", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+}
+
+func TestShiftedYearsDoNotDrift(t *testing.T) {
+	// 4 марта 2023 — суббота: перенос на понедельник 6 марта не сдвигает
+	// следующий год, он снова считается от 4 марта
+	resp := getOccurrences(t, url.Values{"date": {"20220304"}, "repeat": {"y >"}, "count": {"3"}})
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, []string{"20230306", "20240304", "20250304"}, resp.Dates)
+
+	// то же для задачи, которую выполняют два года подряд: ищется будущая
+	// дата, которая через год выпадает на субботу
+	weekday := func(d time.Time) bool {
+		return d.Weekday() != time.Saturday && d.Weekday() != time.Sunday
+	}
+	start := time.Now().AddDate(0, 0, 1)
+	for !(weekday(start) && start.AddDate(1, 0, 0).Weekday() == time.Saturday &&
+		weekday(start.AddDate(2, 0, 0)) && start.Month() != time.February) {
+		start = start.AddDate(0, 0, 1)
+	}
+	shifted := start.AddDate(1, 0, 2).Format(`20060102`)
+	second := start.AddDate(2, 0, 0).Format(`20060102`)
+
+	id := addTask(t, task{date: start.Format(`20060102`), title: "Перенос по годам", repeat: "y >"})
+	done := func() string {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+		body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+		assert.NoError(t, err)
+		return string(body)
+	}
+	assert.Contains(t, done(), shifted)
+	assert.Contains(t, done(), second)
+
+	// отмена возвращает и дату до переноса
+	ret, err := postJSON("api/task/undo?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	assert.Contains(t, done(), second)
+}
@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoneFromCompletion(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	date := now.AddDate(0, 0, 3).Format(`20060102`)
+	for _, v := range []struct {
+		fromCompletion bool
+		want           string
+	}{
+		{false, now.AddDate(0, 0, 10).Format(`20060102`)},
+		{true, now.AddDate(0, 0, 7).Format(`20060102`)},
+	} {
+		ret, err := postJSON("api/task", map[string]any{
+			"date":            date,
+			"title":           "Полить цветы",
+			"repeat":          "d 7",
+			"from_completion": v.fromCompletion,
+		}, http.MethodPost)
+		assert.NoError(t, err)
+		id := fmt.Sprint(ret["id"])
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, v.fromCompletion, task.FromCompletion)
+
+		// задача выполнена раньше срока
+		ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, v.want, task.Date, "from_completion=%v", v.fromCompletion)
+	}
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateCron(t *testing.T) {
+	// 20240126 — пятница
+	checkNextDates(t, []nextDate{
+		{"20240126", "0 9 * * 1-5", "20240129"},
+		{"20240126", "0 9 * * MON-FRI", "20240129"},
+		{"20240126", "0 9 * * 0", "20240128"},
+		{"20240126", "0 9 * * 7", "20240128"},
+		{"20240101", "30 8 1,15 * *", "20240201"},
+		{"20240126", "0 0 13 * 5", "20240202"},
+		{"20240126", "0 0 29 2 *", "20240229"},
+		{"20240301", "0 0 29 2 *", "20280229"},
+		{"20240126", "0 9 */10 * *", "20240131"},
+		{"20240126", "0 12 * JUL 1", "20240701"},
+		{"20240210", "*/5 * * * *", "20240211"},
+		{"20240126", "0 25 * * *", ""},
+		{"20240126", "0 9 32 * *", ""},
+		{"20240126", "0 9 * * 8", ""},
+		{"20240126", "0 9 5-1 * *", ""},
+		{"20240126", "0 9 31 2 *", ""},
+	})
+}
+
+func TestValidateCron(t *testing.T) {
+	body, err := getBody("api/validate?repeat=" + url.QueryEscape("0 9 * 13 1-5"))
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, false, m["valid"])
+	assert.Equal(t, "invalid_cron", m["code"])
+	assert.Equal(t, "13", m["token"])
+	assert.Equal(t, float64(7), m["position"])
+
+	desc, _ := getDescription(t, url.Values{"repeat": {"0 9 * * 1-5"}, "lang": {"en"}}, "")
+	assert.Equal(t, "every week on Monday, Tuesday, Wednesday, Thursday and Friday at 09:00", desc["description"])
+}
+
+func TestTaskCronTime(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	for _, v := range []struct {
+		time string
+		want string
+	}{
+		{"", "09:30"},
+		{"18:00", "18:00"},
+	} {
+		ret, err := postJSON("api/task", map[string]any{
+			"date":   date,
+			"title":  "Стендап",
+			"repeat": "30 9 * * 1-5",
+			"time":   v.time,
+		}, http.MethodPost)
+		assert.NoError(t, err)
+		id := fmt.Sprint(ret["id"])
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, v.want, task.Time)
+		assert.Equal(t, "30 9 * * 1-5", task.Repeat)
+	}
+}
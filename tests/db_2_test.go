@@ -11,11 +11,28 @@ import (
 )
 
 type Task struct {
-	ID      int64  `db:"id"`
-	Date    string `db:"date"`
-	Title   string `db:"title"`
-	Comment string `db:"comment"`
-	Repeat  string `db:"repeat"`
+	ID             int64   `db:"id"`
+	Date           string  `db:"date"`
+	Title          string  `db:"title"`
+	Comment        string  `db:"comment"`
+	Repeat         string  `db:"repeat"`
+	Until          string  `db:"until"`
+	Remaining      int     `db:"remaining"`
+	Time           string  `db:"time"`
+	Duration       int     `db:"duration"`
+	FromCompletion bool    `db:"from_completion"`
+	Exdates        string  `db:"exdates"`
+	Ease           float64 `db:"ease"`
+	Interval       int     `db:"interval"`
+	Repetitions    int     `db:"repetitions"`
+	Priority       int     `db:"priority"`
+	ListID         int64   `db:"list_id"`
+	Archived       bool    `db:"archived"`
+	DeletedAt      string  `db:"deleted_at"`
+	CreatedAt      string  `db:"created_at"`
+	UpdatedAt      string  `db:"updated_at"`
+	Version        int     `db:"version"`
+	Anchor         string  `db:"anchor"`
 }
 
 func count(db *sqlx.DB) (int, error) {
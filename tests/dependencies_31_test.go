@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDependencies(t *testing.T) {
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	design := addTask(t, task{date: date, title: "Зависимости макет"})
+	code := addTask(t, task{date: date, title: "Зависимости код"})
+	release := addTask(t, task{date: date, title: "Зависимости релиз", repeat: "d 1"})
+
+	depend := func(id, blocker, method string) map[string]any {
+		ret, err := postJSON("api/task/dependency?id="+id+"&blocker="+blocker, nil, method)
+		assert.NoError(t, err)
+		return ret
+	}
+
+	ret := depend(code, design, http.MethodPost)
+	assert.Equal(t, []any{design}, ret["blocked_by"])
+	depend(release, code, http.MethodPost)
+
+	// макет ← код ← релиз: связь релиз ← макет замкнула бы цикл
+	assert.Equal(t, "dependency_cycle", depend(design, release, http.MethodPost)["code"])
+	assert.Equal(t, "dependency_cycle", depend(design, code, http.MethodPost)["code"])
+	assert.Equal(t, "self_dependency", depend(code, code, http.MethodPost)["code"])
+	assert.Equal(t, "blocker_not_found", depend(code, "100000", http.MethodPost)["code"])
+
+	body, err := requestJSON("api/task?id="+code, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var task map[string]any
+	assert.NoError(t, json.Unmarshal(body, &task))
+	assert.Equal(t, []any{design}, task["blocked_by"])
+	assert.Equal(t, []any{release}, task["blocks"])
+
+	search := url.QueryEscape("Зависимости")
+	assert.Equal(t, []string{"Зависимости макет"}, sortedTitles(t, "Зависимости", "unblocked=true&sort=title"))
+	assert.Len(t, sortedTitles(t, "Зависимости", "unblocked=false"), 3)
+	body, err = requestJSON("api/tasks?search="+search+"&unblocked=maybe", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "error")
+
+	// выполнение блокирующей задачи открывает зависящие от неё
+	ret, err = postJSON("api/task/done?id="+design, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, []string{"Зависимости код"}, sortedTitles(t, "Зависимости", "unblocked=true"))
+
+	// связь не удаляется: отмена выполнения снова блокирует зависящие задачи
+	_, err = postJSON("api/task/undo?id="+design, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{design}, depend(code, design, http.MethodPost)["blocked_by"])
+	assert.Equal(t, []string{"Зависимости макет"}, sortedTitles(t, "Зависимости", "unblocked=true"))
+	_, err = postJSON("api/task/done?id="+design, nil, http.MethodPost)
+	assert.NoError(t, err)
+
+	ret = depend(release, code, http.MethodDelete)
+	assert.Nil(t, ret["blocked_by"])
+	assert.Equal(t, "dependency_not_found", depend(release, code, http.MethodDelete)["code"])
+	assert.Equal(t, []string{"Зависимости код", "Зависимости релиз"},
+		sortedTitles(t, "Зависимости", "unblocked=true&sort=title"))
+
+	// повторяющаяся задача тоже перестаёт блокировать после выполнения
+	depend(code, release, http.MethodPost)
+	assert.Equal(t, []string{"Зависимости релиз"}, sortedTitles(t, "Зависимости", "unblocked=true"))
+	ret, err = postJSON("api/task/done?id="+release, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Len(t, sortedTitles(t, "Зависимости", "unblocked=true"), 2)
+
+	_, err = postJSON("api/task/undo?id="+release, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Зависимости релиз"}, sortedTitles(t, "Зависимости", "unblocked=true"))
+	body, err = requestJSON("api/task?id="+code, nil, http.MethodGet)
+	assert.NoError(t, err)
+	task = nil
+	assert.NoError(t, json.Unmarshal(body, &task))
+	assert.Equal(t, []any{release}, task["blocked_by"])
+}
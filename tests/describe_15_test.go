@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getDescription(t *testing.T, params url.Values, acceptLanguage string) (map[string]any, string) {
+	req, err := http.NewRequest(http.MethodGet, getURL("api/describe?"+params.Encode()), nil)
+	assert.NoError(t, err)
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m, resp.Header.Get("Content-Language")
+}
+
+func TestDescribe(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		ru     string
+		en     string
+	}{
+		{"d 1", "каждый день", "every day"},
+		{"d 5", "каждые 5 дней", "every 5 days"},
+		{"d 21", "каждый 21 день", "every 21 days"},
+		{"y", "каждый год", "every year"},
+		{"w 1,3", "каждую неделю по понедельникам и средам", "every week on Monday and Wednesday"},
+		{"m 1,-1 2,8", "1-го числа и в последний день февраля и августа",
+			"on the 1st and last day of February and August"},
+		{"n 2:2,-1:5", "во второй вторник и в последнюю пятницу каждого месяца",
+			"on the second Tuesday and last Friday of every month"},
+		{"n -1:7", "в последнее воскресенье каждого месяца", "on the last Sunday of every month"},
+		{"b 3", "каждые 3 рабочих дня", "every 3 working days"},
+		{"d 7 >", "каждые 7 дней, с переносом на следующий рабочий день",
+			"every 7 days, moved to the next working day if it falls on a day off"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU", "каждые 2 недели по вторникам", "every 2 weeks on Tuesday"},
+	}
+	for _, v := range tbl {
+		m, lang := getDescription(t, url.Values{"repeat": {v.repeat}}, "")
+		assert.Empty(t, m["error"], v.repeat)
+		assert.Equal(t, v.ru, m["description"], v.repeat)
+		assert.Equal(t, "ru", lang)
+
+		m, lang = getDescription(t, url.Values{"repeat": {v.repeat}}, "en-US,en;q=0.9,ru;q=0.8")
+		assert.Empty(t, m["error"], v.repeat)
+		assert.Equal(t, v.en, m["description"], v.repeat)
+		assert.Equal(t, "en", lang)
+	}
+
+	m, _ := getDescription(t, url.Values{"repeat": {"d 1"}, "until": {"20250301"}}, "")
+	assert.Equal(t, "каждый день, до 1 марта 2025", m["description"])
+	m, _ = getDescription(t, url.Values{"repeat": {"d 1"}, "remaining": {"3"}, "lang": {"en"}}, "ru")
+	assert.Equal(t, "every day, 3 occurrences left", m["description"])
+
+	for _, repeat := range []string{"", "w 8", "w 1 >", "k 1"} {
+		m, _ = getDescription(t, url.Values{"repeat": {repeat}}, "")
+		assert.NotEmpty(t, m["error"], repeat)
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateExdates(t *testing.T) {
+	tbl := []struct {
+		date   string
+		repeat string
+		params string
+		want   string
+	}{
+		{"20240120", "d 7", "exdates=20240127", "20240203"},
+		{"20240120", "d 7", "exdates=20240203,20240127", "20240210"},
+		{"20240126", "w 1", "exdates=20240129", "20240205"},
+		{"20240120", "d 7", "exdates=20240127&remaining=2", "20240203"},
+		{"20240120", "d 7", "exdates=20240127&until=20240130", ""},
+		{"20240120", "d 7", "exdates=2024", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s&%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat), v.params)
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q, %q}`,
+			v.date, v.repeat, v.params, v.want)
+	}
+
+	desc, _ := getDescription(t, url.Values{"repeat": {"d 7"}, "exdates": {"20240205"}}, "")
+	assert.Equal(t, "каждые 7 дней, кроме 5 февраля 2024", desc["description"])
+}
+
+func TestTaskExdates(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	ret, err := postJSON("api/task", map[string]any{
+		"date":   day(1),
+		"title":  "Тренировка",
+		"repeat": "d 7",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+
+	ret, err = postJSON("api/task/exdate?id="+id+"&date="+day(8), nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, day(1), ret["date"])
+	assert.Equal(t, []any{day(8)}, ret["exdates"])
+
+	// исключённая дата пропускается при выполнении
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, day(15), task.Date)
+
+	// исключение текущей даты переносит задачу
+	ret, err = postJSON("api/task/exdate?id="+id+"&date="+day(15), nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, day(22), ret["date"])
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, day(22), task.Date)
+	assert.Equal(t, day(8)+","+day(15), task.Exdates)
+
+	ret, err = postJSON("api/task/exdate?id="+id+"&date="+day(8), nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{day(15)}, ret["exdates"])
+
+	ret, err = postJSON("api/task/exdate?id="+id+"&date="+day(8), nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, "exdate_not_found", ret["code"])
+
+	ret, err = postJSON("api/task/exdate?id="+id+"&date=2024", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "invalid_exdate", ret["code"])
+
+	// у одноразовой задачи нет серии, из которой можно исключить дату
+	ret, err = postJSON("api/task", map[string]any{
+		"date":  day(1),
+		"title": "Позвонить",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	once := fmt.Sprint(ret["id"])
+	ret, err = postJSON("api/task/exdate?id="+once+"&date="+day(1), nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "exdates_without_repeat", ret["code"])
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type historyResponse struct {
+	Completions []struct {
+		TaskID      string `json:"task_id"`
+		Title       string `json:"title"`
+		Date        string `json:"date"`
+		CompletedAt string `json:"completed_at"`
+	} `json:"completions"`
+	Error string `json:"error"`
+}
+
+func getHistory(t *testing.T, query string) historyResponse {
+	body, err := requestJSON("api/history?"+query, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var resp historyResponse
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	return resp
+}
+
+func TestHistory(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+	once := addTask(t, task{
+		date:  today,
+		title: "Отправить отчёт",
+	})
+	ret, err := postJSON("api/task/done?id="+once, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	// выполненная одноразовая задача остаётся в таблице, но уходит в архив
+	notFoundTask(t, once)
+	var archived Task
+	err = db.Get(&archived, `SELECT * FROM scheduler WHERE id=?`, once)
+	assert.NoError(t, err)
+	assert.True(t, archived.Archived)
+	body, err := requestJSON("api/tasks?search="+url.QueryEscape("Отправить отчёт"), nil, http.MethodGet)
+	assert.NoError(t, err)
+	var tasks map[string][]map[string]any
+	assert.NoError(t, json.Unmarshal(body, &tasks))
+	for _, v := range tasks["tasks"] {
+		assert.NotEqual(t, once, v["id"])
+	}
+
+	repeating := addTask(t, task{
+		date:   today,
+		title:  "Полить цветы",
+		repeat: "d 2",
+	})
+	for i := 0; i < 2; i++ {
+		ret, err = postJSON("api/task/done?id="+repeating, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+
+	resp := getHistory(t, "id="+repeating)
+	assert.Empty(t, resp.Error)
+	if assert.Len(t, resp.Completions, 2) {
+		// новые выполнения идут первыми
+		assert.Equal(t, now.AddDate(0, 0, 2).Format(`20060102`), resp.Completions[0].Date)
+		assert.Equal(t, today, resp.Completions[1].Date)
+		assert.Equal(t, "Полить цветы", resp.Completions[0].Title)
+		_, err = time.Parse(time.RFC3339, resp.Completions[0].CompletedAt)
+		assert.NoError(t, err)
+	}
+
+	resp = getHistory(t, "id="+once+"&from="+today+"&to="+today)
+	if assert.Len(t, resp.Completions, 1) {
+		assert.Equal(t, once, resp.Completions[0].TaskID)
+		assert.Equal(t, "Отправить отчёт", resp.Completions[0].Title)
+	}
+
+	tomorrow := now.AddDate(0, 0, 1).Format(`20060102`)
+	resp = getHistory(t, "id="+once+"&from="+tomorrow)
+	assert.Empty(t, resp.Completions)
+	yesterday := now.AddDate(0, 0, -1).Format(`20060102`)
+	resp = getHistory(t, "id="+once+"&to="+yesterday)
+	assert.Empty(t, resp.Completions)
+
+	// в базе момент выполнения хранится в UTC
+	var completedAt string
+	err = db.Get(&completedAt, `SELECT completed_at FROM completions WHERE task_id=?`, once)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(completedAt, "Z"), completedAt)
+	stored, err := time.Parse(time.RFC3339, completedAt)
+	assert.NoError(t, err)
+
+	// в ответе — в часовом поясе пользователя, дни фильтра тоже считаются в нём:
+	// в Киритимати (UTC+14) и Паго-Паго (UTC-11) даты всегда разные
+	east, err := time.LoadLocation("Pacific/Kiritimati")
+	assert.NoError(t, err)
+	west, err := time.LoadLocation("Pacific/Pago_Pago")
+	assert.NoError(t, err)
+	eastDay := stored.In(east).Format(`20060102`)
+	westDay := stored.In(west).Format(`20060102`)
+	tz := "&tz=" + url.QueryEscape("Pacific/Kiritimati")
+
+	resp = getHistory(t, "id="+once+"&from="+eastDay+"&to="+eastDay+tz)
+	if assert.Len(t, resp.Completions, 1) {
+		assert.Equal(t, stored.In(east).Format(time.RFC3339), resp.Completions[0].CompletedAt)
+	}
+	resp = getHistory(t, "id="+once+"&to="+westDay+tz)
+	assert.Empty(t, resp.Completions)
+	resp = getHistory(t, "id="+once+"&from="+westDay+"&to="+westDay+"&tz="+url.QueryEscape("Pacific/Pago_Pago"))
+	assert.Len(t, resp.Completions, 1)
+
+	resp = getHistory(t, "from=2024")
+	assert.NotEmpty(t, resp.Error)
+}
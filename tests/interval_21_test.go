@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateIntervals(t *testing.T) {
+	tbl := []nextDate{
+		// 20240126 — пятница; недели отсчитываются от недели даты задачи
+		{"20240101", "w 1,4 2", "20240129"},
+		{"20240115", "w 1,4 2", "20240129"},
+		{"20240122", "w 1,4 2", "20240205"},
+		{"20240125", "w 1,4 2", "20240205"},
+		{"20240126", "w 5 3", "20240216"},
+		{"20240102", "w 2 1", "20240130"},
+		{"20240101", "w 1 52", "20241230"},
+		{"20230101", "y 2", "20250101"},
+		{"20220301", "y 2", "20240301"},
+		{"20200229", "y 2", "20240301"},
+		{"20240126", "y 3", "20270126"},
+		{"19000101", "y 100", "21000101"},
+		{"20240126", "w 1,4 0", ""},
+		{"20240126", "w 1,4 53", ""},
+		{"20240126", "w 1,4 2 1", ""},
+		{"20240126", "y 0", ""},
+		{"20240126", "y 101", ""},
+		{"20240126", "y x", ""},
+		{"20240126", "y 2 1", ""},
+	}
+	checkNextDates(t, tbl)
+}
+
+func TestValidateIntervals(t *testing.T) {
+	tbl := []struct {
+		repeat     string
+		code       string
+		token      string
+		suggestion string
+	}{
+		{"w 1,4 60", "invalid_week_interval", "60", "w 1,4"},
+		{"w 1,9 2", "weekday_out_of_range", "9", "w 1 2"},
+		{"y 0", "invalid_year_interval", "0", "y"},
+	}
+	for _, v := range tbl {
+		body, err := getBody("api/validate?repeat=" + url.QueryEscape(v.repeat))
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.Equal(t, false, m["valid"], v.repeat)
+		assert.Equal(t, v.code, m["code"], v.repeat)
+		assert.Equal(t, v.token, m["token"], v.repeat)
+		assert.Equal(t, v.suggestion, m["suggestion"], v.repeat)
+	}
+
+	desc, _ := getDescription(t, url.Values{"repeat": {"w 1,4 2"}, "lang": {"en"}}, "")
+	assert.Equal(t, "every 2 weeks on Monday and Thursday", desc["description"])
+	desc, _ = getDescription(t, url.Values{"repeat": {"y 3"}}, "")
+	assert.Equal(t, "каждые 3 года", desc["description"])
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type listsResponse struct {
+	Lists []struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Tasks int    `json:"tasks"`
+	} `json:"lists"`
+}
+
+func getLists(t *testing.T) listsResponse {
+	body, err := requestJSON("api/lists", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var resp listsResponse
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	return resp
+}
+
+func TestLists(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	lists := getLists(t).Lists
+	if assert.NotEmpty(t, lists) {
+		assert.Equal(t, "1", lists[0].ID)
+		assert.Equal(t, "Входящие", lists[0].Name)
+	}
+
+	ret, err := postJSON("api/list", map[string]any{"name": " Релиз 2.0 "}, http.MethodPost)
+	assert.NoError(t, err)
+	release := fmt.Sprint(ret["id"])
+	ret, err = postJSON("api/list", map[string]any{"name": "Релиз 2.0"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "list_exists", ret["code"])
+	ret, err = postJSON("api/list", map[string]any{"name": " "}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "invalid_list_name", ret["code"])
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	ret, err = postJSON("api/task", map[string]any{
+		"date":    date,
+		"title":   "Списки сборка",
+		"list_id": release,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	build := fmt.Sprint(ret["id"])
+	inbox := addTask(t, task{
+		date:  date,
+		title: "Списки покупки",
+	})
+	ret, err = postJSON("api/task", map[string]any{
+		"date":    date,
+		"title":   "Списки нет",
+		"list_id": "100000",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "list_not_found", ret["code"])
+
+	assert.Equal(t, []string{"Списки сборка"}, sortedTitles(t, "Списки", "list="+release))
+	assert.Equal(t, []string{"Списки покупки"}, sortedTitles(t, "Списки", "list=1"))
+	assert.Equal(t, []string{"Списки покупки", "Списки сборка"}, sortedTitles(t, "Списки", "sort=title"))
+
+	// изменение без list_id оставляет задачу в её списке
+	ret, err = postJSON("api/task", map[string]any{
+		"id":    build,
+		"date":  date,
+		"title": "Списки сборка 2.0",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	var stored Task
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, build)
+	assert.NoError(t, err)
+	assert.Equal(t, release, fmt.Sprint(stored.ListID))
+
+	ret, err = postJSON("api/list", map[string]any{"id": release, "name": "Релиз 2.1"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	for _, list := range getLists(t).Lists {
+		if list.ID == release {
+			assert.Equal(t, "Релиз 2.1", list.Name)
+			assert.Equal(t, 1, list.Tasks)
+		}
+	}
+
+	ret, err = postJSON("api/list?id=1", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, "default_list", ret["code"])
+
+	// задачи удалённого списка переносятся во «Входящие»
+	ret, err = postJSON("api/list?id="+release, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, []string{"Списки покупки", "Списки сборка 2.0"}, sortedTitles(t, "Списки", "list=1&sort=title"))
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, inbox)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stored.ListID)
+}
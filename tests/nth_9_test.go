@@ -0,0 +1,19 @@
+package tests
+
+import "testing"
+
+func TestNextDateNthWeekday(t *testing.T) {
+	tbl := []nextDate{
+		{"20240101", "n 2:2", "20240213"},
+		{"20240101", "n -1:5", "20240223"},
+		{"20240101", "n 1:1 3,9", "20240304"},
+		{"20240101", "n 4:7", "20240128"},
+		{"20240101", "n 1:1,-1:3", "20240131"},
+		{"20240101", "n", ""},
+		{"20240101", "n 2", ""},
+		{"20240101", "n 5:1", ""},
+		{"20240101", "n 2:8", ""},
+		{"20240101", "n 1:1 13", ""},
+	}
+	checkNextDates(t, tbl)
+}
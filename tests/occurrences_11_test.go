@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type occurrencesResponse struct {
+	Dates []string `json:"dates"`
+	Error string   `json:"error"`
+}
+
+func getOccurrences(t *testing.T, params url.Values) occurrencesResponse {
+	body, err := getBody("api/occurrences?" + params.Encode())
+	assert.NoError(t, err)
+	var resp occurrencesResponse
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	return resp
+}
+
+func TestOccurrences(t *testing.T) {
+	resp := getOccurrences(t, url.Values{"date": {"20240126"}, "repeat": {"d 7"}, "count": {"3"}})
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, []string{"20240202", "20240209", "20240216"}, resp.Dates)
+
+	resp = getOccurrences(t, url.Values{"date": {"20240101"}, "repeat": {"w 1,5"},
+		"from": {"20240201"}, "to": {"20240210"}})
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, []string{"20240202", "20240205", "20240209"}, resp.Dates)
+
+	resp = getOccurrences(t, url.Values{"date": {"20240101"}, "repeat": {"m -1"}, "count": {"3"}})
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, []string{"20240131", "20240229", "20240331"}, resp.Dates)
+
+	resp = getOccurrences(t, url.Values{"date": {"20240101"}, "repeat": {"FREQ=WEEKLY;BYDAY=SA"},
+		"count": {"2"}, "to": {"20240110"}})
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, []string{"20240106"}, resp.Dates)
+
+	for _, params := range []url.Values{
+		{"date": {"20240101"}, "repeat": {"d 7"}},
+		{"date": {"20240101"}, "repeat": {"d 7"}, "count": {"5000"}},
+		{"date": {"20240101"}, "repeat": {"d 7"}, "from": {"20240301"}, "to": {"20240201"}},
+		{"date": {"20240101"}, "repeat": {"k 1"}, "count": {"3"}},
+		{"repeat": {"d 7"}, "count": {"3"}},
+	} {
+		resp = getOccurrences(t, params)
+		assert.NotEmpty(t, resp.Error, params.Encode())
+	}
+}
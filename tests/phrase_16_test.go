@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePhrase(t *testing.T) {
+	tbl := []struct {
+		text   string
+		repeat string
+	}{
+		{"каждый вторник и четверг", "w 2,4"},
+		{"every 2 weeks", "w 5 2"},
+		{"каждые две недели", "w 5 2"},
+		{"every month", "m 26"},
+		{"every weekday", "w 1,2,3,4,5"},
+		{"по будням", "w 1,2,3,4,5"},
+		{"по будним дням", "w 1,2,3,4,5"},
+		{"последний день месяца", "m -1"},
+		{"ежедневно", "d 1"},
+		{"every other day", "d 2"},
+		{"1 и 15 числа", "m 1,15"},
+		{"каждые 3 рабочих дня", "b 3"},
+		{"второй вторник месяца", "n 2:2"},
+		{"last Friday of the month", "n -1:5"},
+		{"every Mon, Wed and Fri", "w 1,3,5"},
+		{"каждый год", "y"},
+		{"каждые 2 недели по вторникам", "w 2 2"},
+		{"every 2 weeks on Mon and Thu", "w 1,4 2"},
+		{"каждые 3 года", "y 3"},
+	}
+	// 26 января 2024 года — пятница
+	for _, v := range tbl {
+		body, err := getBody("api/parse?date=20240126&text=" + url.QueryEscape(v.text))
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.Empty(t, m["error"], v.text)
+		assert.Equal(t, v.repeat, m["repeat"], v.text)
+		assert.NotEmpty(t, m["description"], v.text)
+	}
+
+	for _, text := range []string{"", "голубой вторник", "2 вторник", "каждые 500 дней", "каждые 0 дней"} {
+		body, err := getBody("api/parse?text=" + url.QueryEscape(text))
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.Contains(t, m["error"], "cannot parse repeat phrase", text)
+	}
+}
+
+func TestAddTaskRepeatText(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	ret, err := postJSON("api/task", map[string]any{
+		"date":        now.Format(`20060102`),
+		"title":       "Полив цветов",
+		"repeat_text": "каждые 3 дня",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Nil(t, ret["error"])
+	id := fmt.Sprint(ret["id"])
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "d 3", task.Repeat)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"date":        now.Format(`20060102`),
+		"title":       "Непонятно когда",
+		"repeat_text": "иногда по настроению",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Contains(t, ret["error"], `unknown word "иногда"`)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"date":        now.Format(`20060102`),
+		"title":       "Два правила",
+		"repeat":      "d 1",
+		"repeat_text": "каждый день",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateRRule(t *testing.T) {
+	tbl := []nextDate{
+		{"20240126", "FREQ=DAILY;INTERVAL=3", "20240129"},
+		{"20240101", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", "20240129"},
+		{"20240101", "RRULE:FREQ=MONTHLY;BYDAY=2TU", "20240213"},
+		{"20240101", "FREQ=MONTHLY;BYMONTHDAY=-1", "20240131"},
+		{"20200229", "FREQ=YEARLY", "20240229"},
+		{"20240101", "FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU", "20240331"},
+		{"20240101", "FREQ=HOURLY", ""},
+		{"20240101", "FREQ=WEEKLY;BYDAY=XX", ""},
+		{"20240101", "FREQ=MONTHLY;BYMONTHDAY=31;BYMONTH=2", ""},
+		{"20240126", "FREQ=DAILY;INTERVAL=400", "20250301"},
+	}
+	checkNextDates(t, tbl)
+}
+
+func TestRRuleConvertFutureStart(t *testing.T) {
+	// дата задачи позже now: оба правила ищут следующую дату после неё
+	tbl := []nextDate{
+		{"20240315", "m 1", "20240401"},
+		{"20240131", "m 31", "20240331"},
+		{"20240210", "m 5,-1 2,3", "20240229"},
+	}
+	checkNextDates(t, tbl)
+
+	for _, v := range tbl {
+		body, err := getBody("api/rrule?repeat=" + url.QueryEscape(v.repeat) + "&date=" + v.date)
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.Empty(t, m["error"], v.repeat)
+		rule, _ := m["rrule"].(string)
+		assert.NotEmpty(t, rule, v.repeat)
+		checkNextDates(t, []nextDate{{v.date, rule, v.want}})
+	}
+}
+
+func TestRRuleIntervalLimit(t *testing.T) {
+	// INTERVAL ограничен так же, как интервалы коротких правил,
+	// иначе следующая дата выходит за формат YYYYMMDD
+	for _, repeat := range []string{
+		"FREQ=DAILY;INTERVAL=1000000000",
+		"FREQ=DAILY;INTERVAL=401",
+		"FREQ=WEEKLY;INTERVAL=53;BYDAY=TU",
+		"FREQ=WEEKLY;INTERVAL=60;BYDAY=TU",
+		"FREQ=MONTHLY;INTERVAL=1201",
+		"FREQ=YEARLY;INTERVAL=101",
+	} {
+		body, err := getBody("api/nextdate?now=20240126&date=20240126&repeat=" + url.QueryEscape(repeat))
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m), string(body))
+		assert.Contains(t, m["error"], "rrule is incorrect", repeat)
+	}
+
+	ret, err := postJSON("api/task", map[string]any{
+		"date":   "20240126",
+		"title":  "Раз в вечность",
+		"repeat": "FREQ=DAILY;INTERVAL=1000000000",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Contains(t, ret["error"], "rrule is incorrect")
+	assert.Nil(t, ret["id"])
+}
+
+func TestRRuleConvert(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		short  string
+		rrule  string
+	}{
+		{"d 5", "d 5", "FREQ=DAILY;INTERVAL=5"},
+		{"y", "y", "FREQ=YEARLY"},
+		{"w 1,3,7", "w 1,3,7", "FREQ=WEEKLY;BYDAY=MO,WE,SU"},
+		{"m 1,-1 2,5", "m 1,-1 2,5", "FREQ=MONTHLY;BYMONTHDAY=1,-1;BYMONTH=2,5"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-2", "m -2", "FREQ=MONTHLY;BYMONTHDAY=-2"},
+		{"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU", "w 2 2", "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU"},
+		{"FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=5", "", "FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=5"},
+		{"y 3", "y 3", "FREQ=YEARLY;INTERVAL=3"},
+		{"n 2:2,-1:5 1,6", "n 2:2,-1:5 1,6", "FREQ=MONTHLY;BYDAY=2TU,-1FR;BYMONTH=1,6"},
+	}
+	for _, v := range tbl {
+		body, err := getBody("api/rrule?repeat=" + url.QueryEscape(v.repeat))
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.Empty(t, m["error"], v.repeat)
+		short, _ := m["repeat"].(string)
+		assert.Equal(t, v.short, short, v.repeat)
+		assert.Equal(t, v.rrule, m["rrule"], v.repeat)
+	}
+
+	body, err := getBody("api/rrule?repeat=" + url.QueryEscape("w 8"))
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.NotEmpty(t, m["error"])
+}
+
+func TestRRuleConvertLeapDay(t *testing.T) {
+	// от 29 февраля y и FREQ=YEARLY дают разные даты, поэтому друг в друга не переводятся
+	checkNextDates(t, []nextDate{
+		{"20200229", "y", "20240301"},
+		{"20200229", "FREQ=YEARLY", "20240229"},
+	})
+
+	convert := func(repeat, date string) map[string]any {
+		body, err := getBody("api/rrule?repeat=" + url.QueryEscape(repeat) + "&date=" + date)
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+		return m
+	}
+
+	m := convert("y", "20200229")
+	assert.NotEmpty(t, m["error"])
+	assert.Equal(t, "not_convertible", m["code"])
+	m = convert("FREQ=YEARLY", "20200229")
+	assert.Empty(t, m["error"])
+	assert.Empty(t, m["repeat"])
+	assert.Equal(t, "FREQ=YEARLY", m["rrule"])
+
+	// для остальных дат правила совпадают
+	m = convert("y", "20200301")
+	assert.Equal(t, "FREQ=YEARLY", m["rrule"])
+	m = convert("FREQ=YEARLY;INTERVAL=2", "20200228")
+	assert.Equal(t, "y 2", m["repeat"])
+	assert.Empty(t, convert("FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29", "20200229")["error"])
+
+	assert.Equal(t, "invalid_date", convert("y", "2020-02-29")["code"])
+}
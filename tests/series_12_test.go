@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateSeriesEnd(t *testing.T) {
+	tbl := []struct {
+		date   string
+		repeat string
+		params string
+		want   string
+	}{
+		{"20240120", "d 7", "until=20240130", "20240127"},
+		{"20240120", "d 7", "until=20240126", ""},
+		{"20240120", "d 7", "remaining=2", "20240127"},
+		{"20240120", "d 7", "remaining=1", ""},
+		{"20240120", "d 7", "until=2024", ""},
+		{"20240101", "FREQ=WEEKLY;COUNT=5", "", "20240129"},
+		{"20240101", "FREQ=WEEKLY;COUNT=3", "", ""},
+		{"20240101", "FREQ=WEEKLY;UNTIL=20240128T000000Z", "", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s&%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat), v.params)
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q, %q}`,
+			v.date, v.repeat, v.params, v.want)
+	}
+
+	resp := getOccurrences(t, url.Values{"date": {"20240126"}, "repeat": {"d 7"},
+		"count": {"10"}, "remaining": {"3"}})
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, []string{"20240202", "20240209"}, resp.Dates)
+}
+
+func TestDoneSeriesEnd(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	ret, err := postJSON("api/task", map[string]any{
+		"date":      now.Format(`20060102`),
+		"title":     "Три тренировки",
+		"repeat":    "d 1",
+		"remaining": 2,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 1).Format(`20060102`), task.Date)
+	assert.Equal(t, 1, task.Remaining)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"date":   now.Format(`20060102`),
+		"title":  "До конца недели",
+		"repeat": "d 3",
+		"until":  now.AddDate(0, 0, 2).Format(`20060102`),
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id = fmt.Sprint(ret["id"])
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sortedTitles возвращает заголовки задач, найденных по search, в порядке ответа.
+func sortedTitles(t *testing.T, search, query string) []string {
+	body, err := requestJSON("api/tasks?search="+url.QueryEscape(search)+"&"+query, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var resp struct {
+		Tasks []map[string]any `json:"tasks"`
+		Code  string           `json:"code"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	if resp.Code != "" {
+		return []string{resp.Code}
+	}
+	titles := make([]string, 0, len(resp.Tasks))
+	for _, task := range resp.Tasks {
+		titles = append(titles, fmt.Sprint(task["title"]))
+	}
+	return titles
+}
+
+func TestTasksSort(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	for _, v := range []struct {
+		title    string
+		days     int
+		priority int
+	}{
+		{"Сортировка Б", 2, 3},
+		{"Сортировка В", 1, 0},
+		{"Сортировка А", 3, 1},
+	} {
+		ret, err := postJSON("api/task", map[string]any{
+			"date":     now.AddDate(0, 0, v.days).Format(`20060102`),
+			"title":    v.title,
+			"priority": v.priority,
+		}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotNil(t, ret["id"])
+	}
+
+	tbl := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Сортировка В", "Сортировка Б", "Сортировка А"}},
+		{"sort=date&order=desc", []string{"Сортировка А", "Сортировка Б", "Сортировка В"}},
+		{"sort=priority&order=desc", []string{"Сортировка Б", "Сортировка А", "Сортировка В"}},
+		{"sort=priority", []string{"Сортировка В", "Сортировка А", "Сортировка Б"}},
+		{"sort=title", []string{"Сортировка А", "Сортировка Б", "Сортировка В"}},
+		{"sort=created&order=desc", []string{"Сортировка А", "Сортировка В", "Сортировка Б"}},
+		{"sort=size", []string{"invalid_sort"}},
+		{"order=up", []string{"invalid_order"}},
+	}
+	for _, v := range tbl {
+		assert.Equal(t, v.want, sortedTitles(t, "Сортировка", v.query), v.query)
+	}
+
+	ret, err := postJSON("api/task", map[string]any{
+		"date":     now.Format(`20060102`),
+		"title":    "Сортировка Г",
+		"priority": 4,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "invalid_priority", ret["code"])
+}
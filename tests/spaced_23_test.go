@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoneSpaced(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	checkNextDates(t, []nextDate{
+		{"20240120", "s", "20240127"},
+		{"20240130", "s", "20240131"},
+		{"20240126", "s 2", ""},
+		{"20240126", "s >", ""},
+	})
+
+	now := time.Now()
+	ret, err := postJSON("api/task", map[string]any{
+		"date":   now.Format(`20060102`),
+		"title":  "Выучить слова",
+		"repeat": "s",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "invalid_grade", ret["code"])
+
+	for _, v := range []struct {
+		grade       int
+		days        int
+		ease        float64
+		repetitions int
+	}{
+		{5, 1, 2.6, 1},
+		{4, 6, 2.6, 2},
+		{5, 16, 2.7, 3},
+		// плохая оценка начинает повторения заново
+		{1, 1, 2.16, 0},
+		{3, 1, 2.02, 1},
+	} {
+		ret, err = postJSON(fmt.Sprintf("api/task/done?id=%s&grade=%d", id, v.grade), nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, now.AddDate(0, 0, v.days).Format(`20060102`), task.Date, "grade %d", v.grade)
+		assert.Equal(t, v.days, task.Interval, "grade %d", v.grade)
+		assert.InDelta(t, v.ease, task.Ease, 1e-9, "grade %d", v.grade)
+		assert.Equal(t, v.repetitions, task.Repetitions, "grade %d", v.grade)
+	}
+}
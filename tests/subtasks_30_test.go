@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type subtasksTask struct {
+	ID       string `json:"id"`
+	Date     string `json:"date"`
+	Subtasks []struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+		Done  bool   `json:"done"`
+	} `json:"subtasks"`
+	Progress *int   `json:"progress"`
+	Error    string `json:"error"`
+}
+
+func getSubtasksTask(t *testing.T, method, path string) subtasksTask {
+	body, err := requestJSON(path, nil, method)
+	assert.NoError(t, err)
+	var task subtasksTask
+	assert.NoError(t, json.Unmarshal(body, &task))
+	return task
+}
+
+func TestSubtasks(t *testing.T) {
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	ret, err := postJSON("api/task", map[string]any{
+		"date":   day(0),
+		"title":  "Собрать рюкзак",
+		"repeat": "d 7",
+		"subtasks": []map[string]any{
+			{"title": "Палатка"},
+			{"title": "Спальник", "done": true},
+			{"title": " Фонарь "},
+		},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+
+	task := getSubtasksTask(t, http.MethodGet, "api/task?id="+id)
+	if assert.Len(t, task.Subtasks, 3) && assert.NotNil(t, task.Progress) {
+		assert.Equal(t, "Палатка", task.Subtasks[0].Title)
+		assert.Equal(t, "Фонарь", task.Subtasks[2].Title)
+		assert.True(t, task.Subtasks[1].Done)
+		assert.Equal(t, 33, *task.Progress)
+	}
+
+	task = getSubtasksTask(t, http.MethodPost, "api/subtask/done?id="+task.Subtasks[0].ID)
+	if assert.NotNil(t, task.Progress) {
+		assert.Equal(t, 66, *task.Progress)
+		assert.True(t, task.Subtasks[0].Done)
+	}
+	task = getSubtasksTask(t, http.MethodPost, "api/subtask/done?id="+task.Subtasks[1].ID+"&done=false")
+	if assert.NotNil(t, task.Progress) {
+		assert.Equal(t, 33, *task.Progress)
+		assert.False(t, task.Subtasks[1].Done)
+	}
+	task = getSubtasksTask(t, http.MethodPost, "api/subtask/done?id=100000")
+	assert.NotEmpty(t, task.Error)
+
+	// изменение задачи без subtasks сохраняет чек-лист
+	ret, err = postJSON("api/task", map[string]any{
+		"id":     id,
+		"date":   day(0),
+		"title":  "Собрать рюкзак в поход",
+		"repeat": "d 7",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	task = getSubtasksTask(t, http.MethodGet, "api/task?id="+id)
+	assert.Len(t, task.Subtasks, 3)
+	before := task
+
+	// после переноса повторяющейся задачи чек-лист начинается заново
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	task = getSubtasksTask(t, http.MethodGet, "api/task?id="+id)
+	assert.Equal(t, day(7), task.Date)
+	if assert.Len(t, task.Subtasks, 3) && assert.NotNil(t, task.Progress) {
+		assert.Equal(t, 0, *task.Progress)
+		for _, s := range task.Subtasks {
+			assert.False(t, s.Done)
+		}
+	}
+
+	// отмена выполнения возвращает и отметки чек-листа
+	ret, err = postJSON("api/task/undo?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	task = getSubtasksTask(t, http.MethodGet, "api/task?id="+id)
+	assert.Equal(t, before, task)
+
+	// пункты с id обновляются на месте, пункты без id добавляются
+	ret, err = postJSON("api/task", map[string]any{
+		"id":     id,
+		"date":   day(0),
+		"title":  "Собрать рюкзак в поход",
+		"repeat": "d 7",
+		"subtasks": []map[string]any{
+			{"id": before.Subtasks[2].ID, "title": "Фонарик"},
+			{"id": before.Subtasks[0].ID, "title": "Палатка", "done": true},
+			{"title": "Спички"},
+		},
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	task = getSubtasksTask(t, http.MethodGet, "api/task?id="+id)
+	if assert.Len(t, task.Subtasks, 3) {
+		assert.Equal(t, before.Subtasks[2].ID, task.Subtasks[0].ID)
+		assert.Equal(t, "Фонарик", task.Subtasks[0].Title)
+		assert.Equal(t, before.Subtasks[0].ID, task.Subtasks[1].ID)
+		assert.True(t, task.Subtasks[1].Done)
+		assert.Equal(t, "Спички", task.Subtasks[2].Title)
+	}
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"id":       id,
+		"date":     day(7),
+		"title":    "Собрать рюкзак в поход",
+		"repeat":   "d 7",
+		"subtasks": []map[string]any{{"title": "Котелок", "done": true}},
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	task = getSubtasksTask(t, http.MethodGet, "api/task?id="+id)
+	if assert.Len(t, task.Subtasks, 1) && assert.NotNil(t, task.Progress) {
+		assert.Equal(t, "Котелок", task.Subtasks[0].Title)
+		assert.Equal(t, 100, *task.Progress)
+	}
+
+	ret, err = postJSON("api/task", map[string]any{
+		"id":       id,
+		"date":     day(7),
+		"title":    "Собрать рюкзак в поход",
+		"repeat":   "d 7",
+		"subtasks": []map[string]any{},
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	task = getSubtasksTask(t, http.MethodGet, "api/task?id="+id)
+	assert.Empty(t, task.Subtasks)
+	assert.Nil(t, task.Progress)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"date":     day(0),
+		"title":    "Без заголовка пункта",
+		"subtasks": []map[string]any{{"title": " "}},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "empty_subtask_title", ret["code"])
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tagsResponse struct {
+	Tags []struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Tasks int    `json:"tasks"`
+	} `json:"tags"`
+}
+
+func getTags(t *testing.T) tagsResponse {
+	body, err := requestJSON("api/tags", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var resp tagsResponse
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	return resp
+}
+
+func TestTags(t *testing.T) {
+	now := time.Now()
+	date := now.AddDate(0, 0, 1).Format(`20060102`)
+	for _, v := range []struct {
+		title string
+		tags  []string
+	}{
+		{"Метки отчёт", []string{"#Работа", "срочно"}},
+		{"Метки уборка", []string{"дом"}},
+		{"Метки звонок", []string{"работа"}},
+	} {
+		ret, err := postJSON("api/task", map[string]any{
+			"date":  date,
+			"title": v.title,
+			"tags":  v.tags,
+		}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotNil(t, ret["id"])
+	}
+
+	tbl := []struct {
+		query string
+		want  []string
+	}{
+		{"sort=title", []string{"Метки звонок", "Метки отчёт", "Метки уборка"}},
+		{"sort=title&tags=работа", []string{"Метки звонок", "Метки отчёт"}},
+		{"sort=title&tags=работа,срочно", []string{"Метки отчёт"}},
+		{"sort=title&tags=срочно,дом&match=any", []string{"Метки отчёт", "Метки уборка"}},
+		{"sort=title&tags=нет", []string{}},
+		{"match=some", []string{"invalid_match"}},
+		{"tags=a%20b", []string{"invalid_tag"}},
+	}
+	for _, v := range tbl {
+		assert.Equal(t, v.want, sortedTitles(t, "Метки", v.query), v.query)
+	}
+	// метка в строке поиска
+	assert.Equal(t, []string{"Метки звонок", "Метки отчёт"}, sortedTitles(t, "#работа Метки", "sort=title"))
+	assert.Equal(t, []string{"Метки отчёт"}, sortedTitles(t, "отчёт #работа", ""))
+
+	body, err := requestJSON("api/tasks?search=отчёт", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var tasks map[string][]map[string]any
+	assert.NoError(t, json.Unmarshal(body, &tasks))
+	if assert.Len(t, tasks["tasks"], 1) {
+		assert.Equal(t, []any{"работа", "срочно"}, tasks["tasks"][0]["tags"])
+	}
+
+	var work string
+	for _, tag := range getTags(t).Tags {
+		if tag.Name == "работа" {
+			work = tag.ID
+			assert.Equal(t, 2, tag.Tasks)
+		}
+	}
+	assert.NotEmpty(t, work)
+
+	ret, err := postJSON("api/tag", map[string]any{"name": "#Учёба"}, http.MethodPost)
+	assert.NoError(t, err)
+	study := fmt.Sprint(ret["id"])
+	assert.NotEmpty(t, study)
+	ret, err = postJSON("api/tag", map[string]any{"name": "учёба"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "tag_exists", ret["code"])
+
+	ret, err = postJSON("api/tag", map[string]any{"id": work, "name": "учёба"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, "tag_exists", ret["code"])
+	ret, err = postJSON("api/tag", map[string]any{"id": work, "name": "офис"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, []string{"Метки звонок", "Метки отчёт"}, sortedTitles(t, "Метки", "sort=title&tags=офис"))
+
+	ret, err = postJSON("api/tag?id="+work, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, []string{}, sortedTitles(t, "Метки", "tags=офис"))
+	ret, err = postJSON("api/tag?id="+work, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	ret, err = postJSON("api/tag?id="+study, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateTimeOfDay(t *testing.T) {
+	checkNextDates(t, []nextDate{
+		{"20240126 14:30", "d 7", "20240202 14:30"},
+		{"20240120 09:05", "FREQ=WEEKLY;BYDAY=MO", "20240129 09:05"},
+		{"20240126 25:00", "d 7", ""},
+	})
+}
+
+func TestTaskTime(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	for _, v := range []map[string]any{
+		{"title": "Созвон", "time": "1430"},
+		{"title": "Созвон", "time": "14:30", "duration": -5},
+		{"title": "Созвон", "time": "14:30", "duration": 2000},
+	} {
+		ret, err := postJSON("api/task", v, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], v)
+	}
+
+	date := time.Now().AddDate(1, 0, 0)
+	var ids []string
+	for _, tm := range []string{"18:00", "09:00", ""} {
+		ret, err := postJSON("api/task", map[string]any{
+			"date":     date.Format(`20060102`),
+			"title":    "Встреча " + tm,
+			"time":     tm,
+			"duration": 45,
+		}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret["error"])
+		ids = append(ids, fmt.Sprint(ret["id"]))
+	}
+
+	body, err := requestJSON("api/task?id="+ids[0], nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, "18:00", m["time"])
+	assert.Equal(t, float64(45), m["duration"])
+
+	if Search {
+		body, err = requestJSON("api/tasks?search="+date.Format(`02.01.2006`), nil, http.MethodGet)
+		assert.NoError(t, err)
+		var resp struct {
+			Tasks []struct {
+				ID string `json:"id"`
+			} `json:"tasks"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		var got []string
+		for _, task := range resp.Tasks {
+			got = append(got, task.ID)
+		}
+		assert.Equal(t, []string{ids[2], ids[1], ids[0]}, got)
+	}
+
+	ret, err := postJSON("api/task", map[string]any{
+		"title":  "Зарядка",
+		"repeat": "d 1",
+		"time":   "07:15",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var task Task
+	assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, "07:15", task.Time)
+	assert.Equal(t, time.Now().AddDate(0, 0, 1).Format(`20060102`), task.Date)
+
+	for _, id := range append(ids, id) {
+		_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,38 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateTimezone(t *testing.T) {
+	for _, name := range []string{"Pacific/Kiritimati", "Pacific/Pago_Pago"} {
+		loc, err := time.LoadLocation(name)
+		assert.NoError(t, err)
+		want := time.Now().In(loc).AddDate(0, 0, 1).Format(`20060102`)
+
+		body, err := getBody("api/nextdate?date=20240101&repeat=d%201&tz=" + name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, strings.TrimSpace(string(body)), name)
+
+		req, err := http.NewRequest(http.MethodGet, getURL("api/nextdate?date=20240101&repeat=d%201"), nil)
+		assert.NoError(t, err)
+		req.Header.Set("X-Timezone", name)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, want, strings.TrimSpace(string(body)), name)
+	}
+
+	body, err := getBody("api/nextdate?date=20240101&repeat=d%201&tz=Mars/Olympus")
+	assert.NoError(t, err)
+	_, err = time.Parse(`20060102`, strings.TrimSpace(string(body)))
+	assert.Error(t, err)
+}
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTrash(t *testing.T) []map[string]any {
+	body, err := requestJSON("api/trash", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string][]map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m["tasks"]
+}
+
+func TestTrash(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	id := addTask(t, task{
+		date:   time.Now().Format(`20060102`),
+		title:  "Случайно удалённая задача",
+		repeat: "d 5",
+	})
+	ret, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+
+	var trashed Task
+	err = db.Get(&trashed, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	_, err = time.Parse(time.RFC3339, trashed.DeletedAt)
+	assert.NoError(t, err)
+
+	trash := getTrash(t)
+	if assert.NotEmpty(t, trash) {
+		// последние удалённые задачи идут первыми
+		assert.Equal(t, id, trash[0]["id"])
+		assert.Equal(t, trashed.DeletedAt, trash[0]["deleted_at"])
+	}
+
+	// удалённую задачу нельзя изменить или выполнить
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	ret, err = postJSON("api/task/restore?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, id, ret["id"])
+	assert.Equal(t, "Случайно удалённая задача", ret["title"])
+	assert.Empty(t, ret["deleted_at"])
+
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, id, m["id"])
+	for _, v := range getTrash(t) {
+		assert.NotEqual(t, id, v["id"])
+	}
+
+	ret, err = postJSON("api/task/restore?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+	ret, err = postJSON("api/task/restore", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
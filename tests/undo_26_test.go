@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUndoDone(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	ret, err := postJSON("api/task", map[string]any{
+		"date":      day(0),
+		"title":     "Вынести мусор",
+		"repeat":    "d 3",
+		"remaining": 5,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	var stored Task
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, day(3), stored.Date)
+	assert.Equal(t, 4, stored.Remaining)
+
+	// отмена возвращает прежнюю дату и число повторений
+	ret, err = postJSON("api/task/undo?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, day(0), ret["date"])
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, day(0), stored.Date)
+	assert.Equal(t, 5, stored.Remaining)
+	assert.Empty(t, getHistory(t, "id="+id).Completions)
+
+	ret, err = postJSON("api/task/undo?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	// отмена выполнения одноразовой задачи достаёт её из архива
+	once := addTask(t, task{
+		date:  day(0),
+		title: "Оплатить счёт",
+	})
+	ret, err = postJSON("api/task/done?id="+once, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, once)
+
+	ret, err = postJSON("api/task/undo?id="+once, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, once, ret["id"])
+	assert.Equal(t, day(0), ret["date"])
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, once)
+	assert.NoError(t, err)
+	assert.False(t, stored.Archived)
+
+	// выполнение старше TODO_UNDO_WINDOW отменить нельзя
+	ret, err = postJSON("api/task/done?id="+once, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	_, err = db.Exec(`UPDATE completions SET completed_at = ? WHERE task_id = ?`,
+		now.Add(-24*time.Hour).Format(time.RFC3339), once)
+	assert.NoError(t, err)
+	ret, err = postJSON("api/task/undo?id="+once, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+	notFoundTask(t, once)
+}
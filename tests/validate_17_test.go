@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type validation struct {
+	Valid      bool   `json:"valid"`
+	Repeat     string `json:"repeat"`
+	Error      string `json:"error"`
+	Code       string `json:"code"`
+	Field      string `json:"field"`
+	Token      string `json:"token"`
+	Position   int    `json:"position"`
+	Suggestion string `json:"suggestion"`
+}
+
+func TestValidateRepeat(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		want   validation
+	}{
+		{"w 1,3", validation{Valid: true, Repeat: "w 1,3"}},
+		{"FREQ=WEEKLY;COUNT=3", validation{Valid: true, Repeat: "FREQ=WEEKLY"}},
+		{"d 500", validation{Code: "too_many_days", Field: "repeat", Token: "500", Position: 3, Suggestion: "d 400"}},
+		{"d 0", validation{Code: "invalid_day_interval", Field: "repeat", Token: "0", Position: 3, Suggestion: "d 1"}},
+		{"w 1,9,3", validation{Code: "weekday_out_of_range", Field: "repeat", Token: "9", Position: 5, Suggestion: "w 1,3"}},
+		{"m 1,32 2", validation{Code: "invalid_month_day", Field: "repeat", Token: "32", Position: 5, Suggestion: "m 1 2"}},
+		{"m 1 13", validation{Code: "month_out_of_range", Field: "repeat", Token: "13", Position: 5, Suggestion: "m 1"}},
+		{"n 2:2,5:1", validation{Code: "invalid_nth_weekday", Field: "repeat", Token: "5:1", Position: 7, Suggestion: "n 2:2"}},
+		{"w 1 >", validation{Code: "unknown_format", Field: "repeat", Token: ">", Position: 5, Suggestion: "w 1"}},
+		{"d 7 > >", validation{Code: "unknown_format", Field: "repeat", Token: ">", Position: 7, Suggestion: "d 7 >"}},
+		{"m 10 < >", validation{Code: "unknown_format", Field: "repeat", Token: ">", Position: 8, Suggestion: "m 10 <"}},
+		{"D 5", validation{Code: "unknown_format", Field: "repeat", Token: "D", Position: 1, Suggestion: "d 5"}},
+		{"каждый вторник", validation{Code: "unknown_format", Field: "repeat", Token: "каждый", Position: 1, Suggestion: "w 2"}},
+		{"d 1 ", validation{Code: "invalid_day_interval", Field: "repeat", Token: " ", Position: 4, Suggestion: "d 1"}},
+		{"d  1", validation{Code: "invalid_day_interval", Field: "repeat", Token: "  ", Position: 2, Suggestion: "d 1"}},
+		{"m  1,32", validation{Code: "invalid_month_day", Field: "repeat", Token: "  ", Position: 2, Suggestion: "m 1"}},
+		{"RRULE:FREQ=DAILY;BYHOUR=5", validation{Code: "unsupported_rrule", Field: "repeat", Token: "BYHOUR=5",
+			Position: 18, Suggestion: "FREQ=DAILY"}},
+	}
+	for _, v := range tbl {
+		body, err := getBody("api/validate?repeat=" + url.QueryEscape(v.repeat))
+		assert.NoError(t, err)
+		var got validation
+		assert.NoError(t, json.Unmarshal(body, &got))
+		if !v.want.Valid {
+			assert.NotEmpty(t, got.Error, v.repeat)
+			got.Error = ""
+		}
+		assert.Equal(t, v.want, got, v.repeat)
+	}
+
+	body, err := getBody("api/validate?repeat=d+1&until=2024")
+	assert.NoError(t, err)
+	var got validation
+	assert.NoError(t, json.Unmarshal(body, &got))
+	assert.False(t, got.Valid)
+	assert.Equal(t, "invalid_until", got.Code)
+	assert.Equal(t, "until", got.Field)
+}
+
+func TestNextDateRuleError(t *testing.T) {
+	body, err := getBody("api/nextdate?now=20240126&date=20240126&repeat=" + url.QueryEscape("m 1,32"))
+	assert.NoError(t, err)
+	var got validation
+	assert.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, "invalid_month_day", got.Code)
+	assert.Equal(t, "32", got.Token)
+	assert.Equal(t, 5, got.Position)
+}
+
+func TestAddTaskRuleError(t *testing.T) {
+	now := time.Now().Format(`20060102`)
+	tbl := []struct {
+		task  map[string]any
+		code  string
+		field string
+		token string
+	}{
+		{map[string]any{"date": now, "title": "Слишком редко", "repeat": "d 401"}, "too_many_days", "repeat", "401"},
+		{map[string]any{"date": now, "title": "Тринадцатый месяц", "repeat": "m 1 13"}, "month_out_of_range", "repeat", "13"},
+		{map[string]any{"date": "2024013", "title": "Плохая дата"}, "invalid_date", "date", ""},
+		{map[string]any{"date": now, "title": ""}, "empty_title", "title", ""},
+		{map[string]any{"date": now, "title": "Полдень", "time": "12:61"}, "invalid_time", "time", ""},
+		{map[string]any{"date": now, "title": "Когда-нибудь", "repeat_text": "иногда"}, "invalid_phrase", "repeat_text", "иногда"},
+	}
+	for _, v := range tbl {
+		for _, method := range []string{http.MethodPost, http.MethodPut} {
+			if method == http.MethodPut {
+				v.task["id"] = "1"
+			}
+			ret, err := postJSON("api/task", v.task, method)
+			assert.NoError(t, err)
+			if v.code != "empty_title" {
+				assert.NotEqual(t, "пустой заголовок", ret["error"], v.task)
+			}
+			assert.Equal(t, v.code, ret["code"], v.task)
+			assert.Equal(t, v.field, ret["field"], v.task)
+			if v.token != "" {
+				assert.Equal(t, v.token, ret["token"], v.task)
+			}
+		}
+	}
+}
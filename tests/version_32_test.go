@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// versionRequest отправляет запрос с заголовком If-Match и возвращает
+// код ответа, заголовок ETag и тело.
+func versionRequest(t *testing.T, method, apipath, ifMatch string, values map[string]any) (int, string, map[string]any) {
+	var data []byte
+	if values != nil {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return 0, "", nil
+	}
+	defer resp.Body.Close()
+	var m map[string]any
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+	return resp.StatusCode, resp.Header.Get("ETag"), m
+}
+
+func TestTaskVersion(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	status, etag, ret := versionRequest(t, http.MethodPost, "api/task", "", map[string]any{
+		"date":  date,
+		"title": "Версия",
+	})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `"1"`, etag)
+	id := fmt.Sprint(ret["id"])
+
+	status, etag, ret = versionRequest(t, http.MethodGet, "api/task?id="+id, "", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `"1"`, etag)
+	// версия приходит только в ETag: тело задачи остаётся объектом из строк
+	assert.NotContains(t, ret, "version")
+	created, err := time.Parse(time.RFC3339, fmt.Sprint(ret["created_at"]))
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), created, time.Minute)
+	assert.Equal(t, ret["created_at"], ret["updated_at"])
+
+	update := func(title string) map[string]any {
+		return map[string]any{"id": id, "date": date, "title": title}
+	}
+
+	status, etag, ret = versionRequest(t, http.MethodPut, "api/task", `"1"`, update("Версия 2"))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `"2"`, etag)
+	assert.Empty(t, ret)
+
+	// запись по устаревшей версии отклоняется
+	status, _, ret = versionRequest(t, http.MethodPut, "api/task", `"1"`, update("Версия чужая"))
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "version_conflict", ret["code"])
+	body := update("Версия чужая")
+	body["version"] = 1
+	status, _, ret = versionRequest(t, http.MethodPut, "api/task", "", body)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "version_conflict", ret["code"])
+
+	var stored Task
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Версия 2", stored.Title)
+	assert.Equal(t, 2, stored.Version)
+
+	body["version"] = 2
+	status, etag, _ = versionRequest(t, http.MethodPut, "api/task", "", body)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `"3"`, etag)
+	status, etag, _ = versionRequest(t, http.MethodPut, "api/task", `W/"3"`, update("Версия 4"))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `"4"`, etag)
+
+	// без версии задача записывается как раньше
+	status, etag, _ = versionRequest(t, http.MethodPut, "api/task", "", update("Версия 5"))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `"5"`, etag)
+
+	status, _, ret = versionRequest(t, http.MethodPut, "api/task", "abc", update("Версия"))
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_version", ret["code"])
+	body["version"] = 0
+	status, _, ret = versionRequest(t, http.MethodPut, "api/task", "", body)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_version", ret["code"])
+
+	// выполнение тоже меняет версию
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, stored.Version)
+	assert.NotEmpty(t, stored.UpdatedAt)
+}